@@ -6,18 +6,59 @@ type Aggregate struct {
 	fn string
 	arg string
 	alias string
+	// distinct 为 true 的时候只计算不重复的值
+	distinct bool
+}
+
+// Distinct 只计算不重复的值
+// Count("Age").Distinct() => COUNT(DISTINCT `age`)
+func (a Aggregate) Distinct() Aggregate {
+	a.distinct = true
+	return a
 }
 
 func (a Aggregate) selectable() {}
+func (a Aggregate) expr() {}
 
-func (a Aggregate) As(alias string) Aggregate {
-	return Aggregate{
-		fn: a.fn,
-		arg: a.arg,
-		alias: alias,
+// Eq 用于 HAVING 中
+// Avg("Age").Eq(18)
+func (a Aggregate) Eq(arg any) Predicate {
+	return a.binary(opEq, arg)
+}
+
+func (a Aggregate) NotEq(arg any) Predicate {
+	return a.binary(opNotEq, arg)
+}
+
+func (a Aggregate) Gt(arg any) Predicate {
+	return a.binary(opGT, arg)
+}
+
+func (a Aggregate) Gte(arg any) Predicate {
+	return a.binary(opGTE, arg)
+}
+
+func (a Aggregate) Lt(arg any) Predicate {
+	return a.binary(opLT, arg)
+}
+
+func (a Aggregate) Lte(arg any) Predicate {
+	return a.binary(opLTE, arg)
+}
+
+func (a Aggregate) binary(o op, arg any) Predicate {
+	return Predicate{
+		left:  a,
+		op:    o,
+		right: valueOf(arg),
 	}
 }
 
+func (a Aggregate) As(alias string) Aggregate {
+	a.alias = alias
+	return a
+}
+
 func Avg(col string) Aggregate {
 	return Aggregate{
 		fn: "AVG",
@@ -1,8 +1,12 @@
 package orm
 
+// Assignment 代表 col = val 的赋值语句，
+// val 既可以是普通的值，也可以是表达式
+// Assign("Age", 18)
+// Assign("Age", C("Age").Add(1))
 type Assignment struct {
 	col string
-	val any
+	val Expression
 }
 
 func (Assignment) assign() {}
@@ -10,6 +14,6 @@ func (Assignment) assign() {}
 func Assign(col string, val any) Assignment {
 	return Assignment{
 		col: col,
-		val: val,
+		val: valueOf(val),
 	}
 }
@@ -7,14 +7,36 @@ import (
 )
 
 type builder struct {
+	core
 	sb strings.Builder
 	args []any
 	model *model.Model
+	// aliases 是 SELECT 部分定义的别名，
+	// 在 HAVING 和 ORDER BY 里面可以直接引用
+	aliases map[string]struct{}
+	// tablePrefix 不为空的时候，没有指定表的列都会带上这个表名，
+	// 例如 PostgreSQL 的 ON CONFLICT DO UPDATE 里面要区分原本的行和 EXCLUDED
+	tablePrefix string
+	// argCols 和 args 一一对应，是参数绑定的列名，不知道的时候是空字符串
+	argCols []string
+	// argCol 是接下来加入的参数绑定的列
+	argCol string
+	// tables 是 FROM、JOIN 和子查询里面用到的表
+	tables []string
+	// inUpsert 为 true 代表正在构造 UPSERT 的赋值和条件，只有这时候才能用 Excluded
+	inUpsert bool
 
-	dialect Dialect
 	quoter byte
 }
 
+func newBuilder(sess Session) builder {
+	c := sess.getCore()
+	return builder{
+		core:   c,
+		quoter: c.dialect.quoter(),
+	}
+}
+
 func (b *builder) quote(name string) {
 	b.sb.WriteByte(b.quoter)
 	b.sb.WriteString(name)
@@ -30,6 +52,460 @@ func (b *builder) buildColumn(name string) error {
 	return nil
 }
 
+// buildTableColumn 构造某张表上的列，如果表有别名，那么会带上别名 `t1`.`id`，
+// 普通的表没有别名的时候带上表名 `order`.`id`，避免 JOIN 的时候有歧义
+func (b *builder) buildTableColumn(table TableReference, name string) error {
+	if table == nil {
+		if _, ok := b.aliases[name]; ok {
+			b.quote(name)
+			return nil
+		}
+		if b.tablePrefix != "" {
+			b.quote(b.tablePrefix)
+			b.sb.WriteByte('.')
+		}
+		return b.buildColumn(name)
+	}
+	if alias := table.tableAlias(); alias != "" {
+		b.quote(alias)
+		b.sb.WriteByte('.')
+	} else if tab, ok := table.(Table); ok {
+		if err := b.buildTableName(tab); err != nil {
+			return err
+		}
+		b.sb.WriteByte('.')
+	}
+	colName, err := b.colName(table, name)
+	if err != nil {
+		return err
+	}
+	b.quote(colName)
+	return nil
+}
+
+// colName 在 table 对应的元数据里面找到字段 name 对应的列名
+func (b *builder) colName(table TableReference, name string) (string, error) {
+	switch tab := table.(type) {
+	case nil:
+		fd, ok := b.model.FieldMap[name]
+		if !ok {
+			return "", errs.NewErrUnknownField(name)
+		}
+		return fd.ColName, nil
+	case Table:
+		m, err := b.r.Get(tab.entity)
+		if err != nil {
+			return "", err
+		}
+		fd, ok := m.FieldMap[name]
+		if !ok {
+			return "", errs.NewErrUnknownField(name)
+		}
+		return fd.ColName, nil
+	case Subquery:
+		return b.subqueryColName(tab, name)
+	case Join:
+		// 先在左边找，找不到再去右边找
+		colName, err := b.colName(tab.left, name)
+		if err == nil {
+			return colName, nil
+		}
+		return b.colName(tab.right, name)
+	default:
+		return "", errs.NewErrUnsupportedTable(table)
+	}
+}
+
+// subqueryColName 解析子查询的列。
+// 如果子查询指定了 SELECT 的列，那么只能引用这些列，并且优先使用别名；
+// 否则就在子查询的 FROM 部分里面找
+func (b *builder) subqueryColName(sub Subquery, name string) (string, error) {
+	if len(sub.columns) == 0 {
+		return b.colName(sub.table, name)
+	}
+	for _, c := range sub.columns {
+		switch col := c.(type) {
+		case Column:
+			if col.alias != "" {
+				if col.alias == name {
+					return col.alias, nil
+				}
+				continue
+			}
+			if col.name == name {
+				table := col.table
+				if table == nil {
+					table = sub.table
+				}
+				return b.colName(table, name)
+			}
+		default:
+			if alias := selectableAlias(col); alias != "" && alias == name {
+				return alias, nil
+			}
+		}
+	}
+	return "", errs.NewErrUnknownField(name)
+}
+
+// buildSubquery 构造子查询，子查询的参数会按照出现的顺序合并到外层查询里面
+func (b *builder) buildSubquery(sub Subquery, useAlias bool) error {
+	// 这里要拿到没有改写过占位符的 SQL，由最外层统一改写
+	q, err := sub.s.build()
+	if err != nil {
+		return err
+	}
+	b.sb.WriteByte('(')
+	// 去掉最后的分号
+	b.sb.WriteString(q.SQL[:len(q.SQL)-1])
+	b.sb.WriteByte(')')
+	b.args = append(b.args, q.Args...)
+	b.argCols = append(b.argCols, sub.s.argColumns()...)
+	for _, t := range sub.s.tableNames() {
+		b.addTable(t)
+	}
+	if useAlias && sub.alias != "" {
+		b.sb.WriteString(" AS ")
+		b.quote(sub.alias)
+	}
+	return nil
+}
+
+// buildTable 构造 FROM 后面的部分
+func (b *builder) buildTable(table TableReference) error {
+	switch tab := table.(type) {
+	case nil:
+		// 没有指定，就用默认的表名
+		b.quote(b.model.TableName)
+		b.addTable(b.model.TableName)
+	case Table:
+		if err := b.buildTableName(tab); err != nil {
+			return err
+		}
+		if tab.alias != "" {
+			b.sb.WriteString(" AS ")
+			b.quote(tab.alias)
+		}
+	case Subquery:
+		return b.buildSubquery(tab, true)
+	case Join:
+		b.sb.WriteByte('(')
+		if err := b.buildTable(tab.left); err != nil {
+			return err
+		}
+		b.sb.WriteByte(' ')
+		b.sb.WriteString(tab.typ)
+		b.sb.WriteByte(' ')
+		if err := b.buildTable(tab.right); err != nil {
+			return err
+		}
+		if len(tab.using) > 0 {
+			b.sb.WriteString(" USING (")
+			for i, col := range tab.using {
+				if i > 0 {
+					b.sb.WriteByte(',')
+				}
+				// USING 里面的列是两张表共有的，所以不能带别名
+				colName, err := b.colName(tab, col)
+				if err != nil {
+					return err
+				}
+				b.quote(colName)
+			}
+			b.sb.WriteByte(')')
+		}
+		if len(tab.on) > 0 {
+			b.sb.WriteString(" ON ")
+			if err := b.buildPredicates(tab.on); err != nil {
+				return err
+			}
+		}
+		b.sb.WriteByte(')')
+	default:
+		return errs.NewErrUnsupportedTable(table)
+	}
+	return nil
+}
+
+// buildTableName 构造带上数据库的表名 `db`.`table`
+func (b *builder) buildTableName(tab Table) error {
+	m, err := b.r.Get(tab.entity)
+	if err != nil {
+		return err
+	}
+	if tab.db != "" {
+		b.quote(tab.db)
+		b.sb.WriteByte('.')
+	}
+	b.quote(m.TableName)
+	b.addTable(m.TableName)
+	return nil
+}
+
+// buildAggregate 构造聚合函数，useAlias 为 true 的时候会带上别名
+func (b *builder) buildAggregate(a Aggregate, useAlias bool) error {
+	// 聚合函数名
+	b.sb.WriteString(a.fn)
+	b.sb.WriteByte('(')
+	if a.distinct {
+		b.sb.WriteString("DISTINCT ")
+	}
+	if err := b.buildColumn(a.arg); err != nil {
+		return err
+	}
+	b.sb.WriteByte(')')
+	// 聚合函数本身的别名
+	if useAlias && a.alias != "" {
+		b.sb.WriteString(" AS ")
+		b.quote(a.alias)
+	}
+	return nil
+}
+
+// buildFunc 构造函数调用，例如 COALESCE(`last_name`,?)
+// 函数名是直接拼接到 SQL 里面的，所以只允许字母、数字和下划线
+func (b *builder) buildFunc(f FuncExpr) error {
+	if !validFuncName(f.name) {
+		return errs.NewErrInvalidFuncName(f.name)
+	}
+	b.sb.WriteString(f.name)
+	b.sb.WriteByte('(')
+	for i, arg := range f.args {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildExpression(arg); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// buildCase 构造 CASE WHEN ... THEN ... ELSE ... END
+func (b *builder) buildCase(c CaseExpr) error {
+	if len(c.whens) == 0 {
+		return errs.ErrCaseWithoutWhen
+	}
+	b.sb.WriteString("CASE")
+	for _, w := range c.whens {
+		b.sb.WriteString(" WHEN ")
+		if err := b.buildExpression(w.cond); err != nil {
+			return err
+		}
+		b.sb.WriteString(" THEN ")
+		if err := b.buildExpression(w.then); err != nil {
+			return err
+		}
+	}
+	if c.els != nil {
+		b.sb.WriteString(" ELSE ")
+		if err := b.buildExpression(c.els); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteString(" END")
+	return nil
+}
+
+// buildPredicates 把多个 Predicate 用 AND 连接起来之后再构造
+func (b *builder) buildPredicates(ps []Predicate) error {
+	p := ps[0]
+	for i := 1; i < len(ps); i++ {
+		p = p.And(ps[i])
+	}
+	return b.buildExpression(p)
+}
+
+func (b *builder) buildExpression(expr Expression) error {
+	switch exp := expr.(type){
+	case nil:
+	case Predicate:
+		// 在这里处理 p
+		// p.left 构建好
+		// p.op 构建好
+		// p.right 构建好
+		if vs, ok := exp.right.(values); ok && len(vs.vals) == 0 {
+			return b.buildEmptyIn(exp)
+		}
+		return b.buildBinaryExpr(exp.left, exp.op, exp.right)
+	case MathExpr:
+		return b.buildBinaryExpr(exp.left, exp.op, exp.right)
+	case Column:
+		// 在表达式里面，列的别名是没有意义的
+		return b.buildTableColumn(exp.table, exp.name)
+	case ExcludedExpr:
+		if !b.inUpsert {
+			return errs.ErrExcludedOutsideUpsert
+		}
+		fd, ok := b.model.FieldMap[exp.name]
+		if !ok {
+			return errs.NewErrUnknownField(exp.name)
+		}
+		b.dialect.buildExcluded(b, fd.ColName)
+	case Aggregate:
+		return b.buildAggregate(exp, false)
+	case FuncExpr:
+		return b.buildFunc(exp)
+	case CaseExpr:
+		return b.buildCase(exp)
+	case value:
+		b.sb.WriteByte('?')
+		b.addArg(exp.val)
+	case values:
+		b.sb.WriteByte('(')
+		for i, val := range exp.vals {
+			if i > 0 {
+				b.sb.WriteByte(',')
+			}
+			b.sb.WriteByte('?')
+			b.addArg(val)
+		}
+		b.sb.WriteByte(')')
+	case betweenRange:
+		if err := b.buildExpression(exp.low); err != nil {
+			return err
+		}
+		b.sb.WriteString(" AND ")
+		return b.buildExpression(exp.high)
+	case RawExpr:
+		b.sb.WriteByte('(')
+		b.sb.WriteString(exp.raw)
+		b.addArg(exp.args...)
+		b.sb.WriteByte(')')
+	case Subquery:
+		return b.buildSubquery(exp, false)
+	case SubqueryExpr:
+		b.sb.WriteString(exp.pred)
+		b.sb.WriteByte(' ')
+		return b.buildSubquery(exp.s, false)
+	default:
+		return errs.NewErrUnsupportedExpression(expr)
+	}
+	return nil
+}
+
+// buildBinaryExpr 构造二元表达式，左右两边如果也是二元表达式，就用括号括起来
+func (b *builder) buildBinaryExpr(left Expression, o op, right Expression) error {
+	if err := b.buildSubExpr(left); err != nil {
+		return err
+	}
+	if o != "" {
+		b.sb.WriteByte(' ')
+		b.sb.WriteString(o.String())
+		// IS NULL 之类的是没有右边的
+		if right != nil {
+			b.sb.WriteByte(' ')
+		}
+	}
+	// 右边的参数绑定的是左边的列，例如 `age` > ?
+	if c, ok := left.(Column); ok {
+		prev := b.argCol
+		b.argCol, _ = b.colName(c.table, c.name)
+		defer func() {
+			b.argCol = prev
+		}()
+	}
+	return b.buildSubExpr(right)
+}
+
+// buildEmptyIn 处理 IN 一个空列表的情况，IN () 在 SQL 里面是非法的。
+// 没有候选值的时候，IN 永远不成立，NOT IN 永远成立
+func (b *builder) buildEmptyIn(p Predicate) error {
+	// 即便不会用到左边，也要校验一下，避免把错误的字段名吞掉
+	if c, ok := p.left.(Column); ok {
+		if _, err := b.colName(c.table, c.name); err != nil {
+			return err
+		}
+	}
+	if p.op == opNotIn {
+		b.sb.WriteString("1 = 1")
+	} else {
+		b.sb.WriteString("1 = 0")
+	}
+	return nil
+}
+
+func (b *builder) buildSubExpr(expr Expression) error {
+	switch expr.(type) {
+	case Predicate, MathExpr:
+		b.sb.WriteByte('(')
+		if err := b.buildExpression(expr); err != nil {
+			return err
+		}
+		b.sb.WriteByte(')')
+		return nil
+	default:
+		return b.buildExpression(expr)
+	}
+}
+
+// buildConflictColumns 构造 ON CONFLICT 后面的 (col1,col2)
+func (b *builder) buildConflictColumns(cols []string) error {
+	if len(cols) == 0 {
+		return nil
+	}
+	b.sb.WriteByte('(')
+	for i, col := range cols {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildColumn(col); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// buildUpsertUpdate 构造 UPSERT 的赋值和 WHERE 部分，
+// 直接传入 Column 代表用准备插入的那一行的值来更新
+func (b *builder) buildUpsertUpdate(upsert *Upsert) error {
+	b.inUpsert = true
+	defer func() {
+		b.inUpsert = false
+	}()
+	for idx, assign := range upsert.assigns {
+		if idx > 0 {
+			b.sb.WriteByte(',')
+		}
+		switch a := assign.(type) {
+		case Assignment:
+			// 被赋值的列不能带表名
+			if err := b.buildColumn(a.col); err != nil {
+				return err
+			}
+			b.sb.WriteByte('=')
+			if err := b.buildAssignValue(a); err != nil {
+				return err
+			}
+		case Column:
+			if err := b.buildColumn(a.name); err != nil {
+				return err
+			}
+			b.sb.WriteByte('=')
+			if err := b.buildExpression(Excluded(a.name)); err != nil {
+				return err
+			}
+		default:
+			return errs.NewErrUnsupportedAssignable(assign)
+		}
+	}
+	if len(upsert.where) > 0 {
+		b.sb.WriteString(" WHERE ")
+		return b.buildPredicates(upsert.where)
+	}
+	return nil
+}
+
+// buildQuery 生成最终的 Query，占位符会按照方言改写
+func (b *builder) buildQuery() *Query {
+	return &Query{
+		SQL:  b.dialect.rebind(b.sb.String()),
+		Args: b.args,
+	}
+}
+
 func (b *builder) addArg(vals ...any) {
 	if len(vals) == 0 {
 		return
@@ -38,4 +514,31 @@ func (b *builder) addArg(vals ...any) {
 		b.args = make([]any, 0, 8)
 	}
 	b.args = append(b.args, vals...)
-}
\ No newline at end of file
+	for range vals {
+		b.argCols = append(b.argCols, b.argCol)
+	}
+}
+
+func (b *builder) addTable(name string) {
+	for _, t := range b.tables {
+		if t == name {
+			return
+		}
+	}
+	b.tables = append(b.tables, name)
+}
+
+// addColArg 加入一个绑定到 col 上的参数
+func (b *builder) addColArg(col string, val any) {
+	b.args = append(b.args, val)
+	b.argCols = append(b.argCols, col)
+}
+
+// buildAssignValue 构造赋值语句的右边，参数绑定的是被赋值的列
+func (b *builder) buildAssignValue(a Assignment) error {
+	prev := b.argCol
+	b.argCol = b.model.FieldMap[a.col].ColName
+	err := b.buildExpression(a.val)
+	b.argCol = prev
+	return err
+}
@@ -0,0 +1,54 @@
+package orm
+
+// CaseExpr 代表 CASE WHEN 表达式
+// Case().When(C("Age").Lt(18), "child").Else("adult").As("stage")
+type CaseExpr struct {
+	whens []caseWhen
+	// els 为 nil 代表没有 ELSE
+	els   Expression
+	alias string
+}
+
+type caseWhen struct {
+	cond Expression
+	then Expression
+}
+
+func Case() CaseExpr {
+	return CaseExpr{}
+}
+
+// When 在 cond 成立的时候返回 then，then 可以是表达式，也可以是普通的值
+func (c CaseExpr) When(cond Predicate, then any) CaseExpr {
+	// 不要修改原本的切片，不然 c 被复用的时候会互相影响
+	whens := make([]caseWhen, 0, len(c.whens)+1)
+	whens = append(whens, c.whens...)
+	c.whens = append(whens, caseWhen{
+		cond: cond,
+		then: valueOf(then),
+	})
+	return c
+}
+
+// Else 所有的 WHEN 都不成立的时候返回 val
+func (c CaseExpr) Else(val any) CaseExpr {
+	c.els = valueOf(val)
+	return c
+}
+
+// As 在 SELECT 里面使用的时候的别名
+func (c CaseExpr) As(alias string) CaseExpr {
+	c.alias = alias
+	return c
+}
+
+func (c CaseExpr) Eq(arg any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opEq,
+		right: valueOf(arg),
+	}
+}
+
+func (c CaseExpr) expr()       {}
+func (c CaseExpr) selectable() {}
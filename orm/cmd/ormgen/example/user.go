@@ -0,0 +1,32 @@
+// Package example 演示 ormgen 的用法，user_orm_gen.go 是生成的代码
+package example
+
+import (
+	"database/sql"
+	"time"
+)
+
+//go:generate go run .. -type=User,Order
+
+type BaseModel struct {
+	Id         int64 `orm:"pk,auto_increment"`
+	CreateTime time.Time
+}
+
+type User struct {
+	BaseModel
+	FirstName string         `orm:"column=first_name_t,size=64"`
+	Email     sql.NullString `orm:"unique"`
+	Age       *int8
+	Password  string `orm:"-"`
+}
+
+type Order struct {
+	Id     int64   `orm:"pk,auto_increment"`
+	UserId int64   `orm:"index"`
+	Amount float64 `orm:"type=DECIMAL(10,2),default=0"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
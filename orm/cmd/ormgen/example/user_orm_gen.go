@@ -0,0 +1,256 @@
+// Code generated by ormgen. DO NOT EDIT.
+
+package example
+
+import (
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"reflect"
+	"unsafe"
+)
+
+// UserColumns 是 User 的列，用它代替 orm.C("FieldName")，写错了编译就过不了
+var UserColumns = struct {
+	Id         orm.Column
+	CreateTime orm.Column
+	FirstName  orm.Column
+	Email      orm.Column
+	Age        orm.Column
+}{
+	Id:         orm.C("Id"),
+	CreateTime: orm.C("CreateTime"),
+	FirstName:  orm.C("FirstName"),
+	Email:      orm.C("Email"),
+	Age:        orm.C("Age"),
+}
+
+// OrmModel 返回 User 的元数据，注册的时候不需要再解析结构体
+func (*User) OrmModel() (*model.Model, error) {
+	var val User
+	return model.NewModel("user", []*model.Field{
+		{
+			GoName:        "Id",
+			ColName:       "id",
+			Type:          reflect.TypeOf(val.BaseModel.Id),
+			Offset:        unsafe.Offsetof(val.BaseModel) + unsafe.Offsetof(val.BaseModel.Id),
+			PrimaryKey:    true,
+			AutoIncrement: true,
+		},
+		{
+			GoName:  "CreateTime",
+			ColName: "create_time",
+			Type:    reflect.TypeOf(val.BaseModel.CreateTime),
+			Offset:  unsafe.Offsetof(val.BaseModel) + unsafe.Offsetof(val.BaseModel.CreateTime),
+		},
+		{
+			GoName:  "FirstName",
+			ColName: "first_name_t",
+			Type:    reflect.TypeOf(val.FirstName),
+			Offset:  unsafe.Offsetof(val.FirstName),
+			Size:    64,
+		},
+		{
+			GoName:  "Email",
+			ColName: "email",
+			Type:    reflect.TypeOf(val.Email),
+			Offset:  unsafe.Offsetof(val.Email),
+			Unique:  "uk_user_email",
+		},
+		{
+			GoName:  "Age",
+			ColName: "age",
+			Type:    reflect.TypeOf(val.Age),
+			Offset:  unsafe.Offsetof(val.Age),
+		},
+	})
+}
+
+// OrmValue 返回读写 User 字段的 orm.Valuer，不需要反射也不需要 unsafe
+func (e *User) OrmValue(m *model.Model, ignoreUnknown bool) orm.Valuer {
+	return userValuer{
+		model:         m,
+		val:           e,
+		ignoreUnknown: ignoreUnknown,
+	}
+}
+
+type userValuer struct {
+	model         *model.Model
+	val           *User
+	ignoreUnknown bool
+}
+
+func (v userValuer) Field(name string) (any, error) {
+	switch name {
+	case "Id":
+		return v.val.BaseModel.Id, nil
+	case "CreateTime":
+		return v.val.BaseModel.CreateTime, nil
+	case "FirstName":
+		return v.val.FirstName, nil
+	case "Email":
+		return v.val.Email, nil
+	case "Age":
+		return v.val.Age, nil
+	}
+	return nil, orm.NewErrUnknownField(name)
+}
+
+func (v userValuer) SetField(name string, val any) error {
+	switch name {
+	case "Id":
+		return orm.SetFieldValue(&v.val.BaseModel.Id, val)
+	case "CreateTime":
+		return orm.SetFieldValue(&v.val.BaseModel.CreateTime, val)
+	case "FirstName":
+		return orm.SetFieldValue(&v.val.FirstName, val)
+	case "Email":
+		return orm.SetFieldValue(&v.val.Email, val)
+	case "Age":
+		return orm.SetFieldValue(&v.val.Age, val)
+	}
+	return orm.NewErrUnknownField(name)
+}
+
+func (v userValuer) SetColumns(rows *sql.Rows) error {
+	cs, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	vals := make([]any, 0, len(cs))
+	for _, c := range cs {
+		fd, ok := v.model.ColumnMap[c]
+		if !ok {
+			if !v.ignoreUnknown {
+				return orm.NewErrUnknownColumn(c)
+			}
+			vals = append(vals, new(any))
+			continue
+		}
+		switch fd.GoName {
+		case "Id":
+			vals = append(vals, &v.val.BaseModel.Id)
+		case "CreateTime":
+			vals = append(vals, &v.val.BaseModel.CreateTime)
+		case "FirstName":
+			vals = append(vals, &v.val.FirstName)
+		case "Email":
+			vals = append(vals, &v.val.Email)
+		case "Age":
+			vals = append(vals, &v.val.Age)
+		default:
+			return orm.NewErrUnknownField(fd.GoName)
+		}
+	}
+	return rows.Scan(vals...)
+}
+
+// OrderColumns 是 Order 的列，用它代替 orm.C("FieldName")，写错了编译就过不了
+var OrderColumns = struct {
+	Id     orm.Column
+	UserId orm.Column
+	Amount orm.Column
+}{
+	Id:     orm.C("Id"),
+	UserId: orm.C("UserId"),
+	Amount: orm.C("Amount"),
+}
+
+// OrmModel 返回 Order 的元数据，注册的时候不需要再解析结构体
+func (*Order) OrmModel() (*model.Model, error) {
+	var val Order
+	return model.NewModel("orders", []*model.Field{
+		{
+			GoName:        "Id",
+			ColName:       "id",
+			Type:          reflect.TypeOf(val.Id),
+			Offset:        unsafe.Offsetof(val.Id),
+			PrimaryKey:    true,
+			AutoIncrement: true,
+		},
+		{
+			GoName:  "UserId",
+			ColName: "user_id",
+			Type:    reflect.TypeOf(val.UserId),
+			Offset:  unsafe.Offsetof(val.UserId),
+			Index:   "idx_orders_user_id",
+		},
+		{
+			GoName:  "Amount",
+			ColName: "amount",
+			Type:    reflect.TypeOf(val.Amount),
+			Offset:  unsafe.Offsetof(val.Amount),
+			Default: "0",
+			SQLType: "DECIMAL(10,2)",
+		},
+	})
+}
+
+// OrmValue 返回读写 Order 字段的 orm.Valuer，不需要反射也不需要 unsafe
+func (e *Order) OrmValue(m *model.Model, ignoreUnknown bool) orm.Valuer {
+	return orderValuer{
+		model:         m,
+		val:           e,
+		ignoreUnknown: ignoreUnknown,
+	}
+}
+
+type orderValuer struct {
+	model         *model.Model
+	val           *Order
+	ignoreUnknown bool
+}
+
+func (v orderValuer) Field(name string) (any, error) {
+	switch name {
+	case "Id":
+		return v.val.Id, nil
+	case "UserId":
+		return v.val.UserId, nil
+	case "Amount":
+		return v.val.Amount, nil
+	}
+	return nil, orm.NewErrUnknownField(name)
+}
+
+func (v orderValuer) SetField(name string, val any) error {
+	switch name {
+	case "Id":
+		return orm.SetFieldValue(&v.val.Id, val)
+	case "UserId":
+		return orm.SetFieldValue(&v.val.UserId, val)
+	case "Amount":
+		return orm.SetFieldValue(&v.val.Amount, val)
+	}
+	return orm.NewErrUnknownField(name)
+}
+
+func (v orderValuer) SetColumns(rows *sql.Rows) error {
+	cs, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	vals := make([]any, 0, len(cs))
+	for _, c := range cs {
+		fd, ok := v.model.ColumnMap[c]
+		if !ok {
+			if !v.ignoreUnknown {
+				return orm.NewErrUnknownColumn(c)
+			}
+			vals = append(vals, new(any))
+			continue
+		}
+		switch fd.GoName {
+		case "Id":
+			vals = append(vals, &v.val.Id)
+		case "UserId":
+			vals = append(vals, &v.val.UserId)
+		case "Amount":
+			vals = append(vals, &v.val.Amount)
+		default:
+			return orm.NewErrUnknownField(fd.GoName)
+		}
+	}
+	return rows.Scan(vals...)
+}
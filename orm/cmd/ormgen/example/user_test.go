@@ -0,0 +1,72 @@
+package example
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// rawUser 没有生成的方法，注册的时候会走反射
+type rawUser User
+
+func (rawUser) TableName() string {
+	return "user"
+}
+
+func TestUser_OrmModel(t *testing.T) {
+	want, err := model.NewRegistry().Register(&rawUser{})
+	require.NoError(t, err)
+	got, err := model.NewRegistry().Register(&User{})
+	require.NoError(t, err)
+	assert.Equal(t, want.Fields, got.Fields)
+	assert.Equal(t, "user", got.TableName)
+
+	m, err := model.NewRegistry().Register(&Order{}, model.WithColumnName("Amount", "total"))
+	require.NoError(t, err)
+	assert.Equal(t, "orders", m.TableName)
+	assert.Equal(t, "Amount", m.ColumnMap["total"].GoName)
+}
+
+func TestUser_CRUD(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite3", "file:ormgen_example.db?cache=shared&mode=memory")
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	_, err = sqlDB.Exec("CREATE TABLE `user` (`id` INTEGER PRIMARY KEY AUTOINCREMENT, " +
+		"`create_time` DATETIME, `first_name_t` TEXT, `email` TEXT, `age` INTEGER)")
+	require.NoError(t, err)
+	db, err := orm.OpenDB(sqlDB, orm.DBWithDialect(orm.DialectSQLite))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	age := int8(18)
+	u := &User{
+		BaseModel: BaseModel{CreateTime: time.UnixMilli(1000).UTC()},
+		FirstName: "Tom",
+		Email:     sql.NullString{String: "tom@example.com", Valid: true},
+		Age:       &age,
+	}
+	res := orm.NewInserter[User](db).Values(u).Exec(ctx)
+	require.NoError(t, res.Err())
+	// 回填主键走的是生成的 SetField
+	assert.Equal(t, int64(1), u.Id)
+
+	got, err := orm.NewSelector[User](db).
+		Where(UserColumns.FirstName.Eq("Tom")).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, u, got)
+
+	// 多出来的列
+	_, err = orm.RawQuery[User](db, "SELECT *, 1 AS `extra` FROM `user`").Get(ctx)
+	assert.Equal(t, orm.NewErrUnknownColumn("extra"), err)
+	db, err = orm.OpenDB(sqlDB, orm.DBWithDialect(orm.DialectSQLite), orm.DBIgnoreUnknownColumns())
+	require.NoError(t, err)
+	got, err = orm.RawQuery[User](db, "SELECT *, 1 AS `extra` FROM `user`").Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, u, got)
+}
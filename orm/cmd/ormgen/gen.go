@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const genSuffix = "_orm_gen.go"
+
+// outputName 默认的输出文件名
+func outputName(typeName string) string {
+	return model.UnderscoreName(lowerFirst(typeName)) + genSuffix
+}
+
+// pkgInfo 是从源码里面解析出来的包信息，只用到了语法树，没有做类型检查
+type pkgInfo struct {
+	name    string
+	structs map[string]*ast.StructType
+	// tableNames 是 TableName 方法返回的表名，方法返回的不是字面量的话是 nil
+	tableNames map[string]*string
+	// scanners 实现了 Scan 或者 Value 方法的类型，它们和 time.Time 一样当成一列
+	scanners map[string]bool
+	// imports 是包名到导入路径的映射
+	imports map[string]string
+}
+
+// columnTypes 别的包里面组合进来的时候可以当成一列的类型，
+// 其余的类型没有做类型检查就不知道要不要展开
+var columnTypes = map[string]bool{
+	"time.Time":                true,
+	"database/sql.NullBool":    true,
+	"database/sql.NullByte":    true,
+	"database/sql.NullFloat64": true,
+	"database/sql.NullInt16":   true,
+	"database/sql.NullInt32":   true,
+	"database/sql.NullInt64":   true,
+	"database/sql.NullString":  true,
+	"database/sql.NullTime":    true,
+}
+
+type modelInfo struct {
+	Name       string
+	ValuerName string
+	TableName  string
+	Fields     []fieldInfo
+}
+
+type fieldInfo struct {
+	GoName  string
+	ColName string
+	// Path 是从最外层结构体访问这个字段的路径，例如 BaseModel.Id
+	Path string
+	// Offset 是计算偏移量的表达式
+	Offset string
+	// Attrs 是元数据里面除了名字、类型和偏移量以外的部分
+	Attrs []string
+}
+
+// fieldWithDepth 和 model 里面一样，外层的字段会覆盖组合进来的同名字段
+type fieldWithDepth struct {
+	fieldInfo
+	depth int
+}
+
+// generate 解析 dir 里面的源码，为 types 生成代码
+func generate(dir string, types []string) ([]byte, error) {
+	pkg, err := parsePackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]modelInfo, 0, len(types))
+	for _, typ := range types {
+		m, err := pkg.model(strings.TrimSpace(typ))
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, m)
+	}
+	buf := &bytes.Buffer{}
+	err = genTpl.Execute(buf, map[string]any{
+		"Package": pkg.name,
+		"Models":  models,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func parsePackage(dir string) (*pkgInfo, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	pkg := &pkgInfo{
+		structs:    map[string]*ast.StructType{},
+		tableNames: map[string]*string{},
+		scanners:   map[string]bool{},
+		imports:    map[string]string{},
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		// 不解析测试和自己生成的代码
+		if strings.HasSuffix(file, "_test.go") || strings.HasSuffix(file, genSuffix) {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, file, src, 0)
+		if err != nil {
+			return nil, err
+		}
+		pkg.name = f.Name.Name
+		pkg.collect(f)
+	}
+	if pkg.name == "" {
+		return nil, fmt.Errorf("%s 里面没有 Go 源码", dir)
+	}
+	return pkg, nil
+}
+
+// collect 收集结构体的定义和相关的方法
+func (p *pkgInfo) collect(f *ast.File) {
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := path[strings.LastIndexByte(path, '/')+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		p.imports[name] = path
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					p.structs[ts.Name.Name] = st
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) != 1 {
+				continue
+			}
+			recv := receiverName(d.Recv.List[0].Type)
+			switch d.Name.Name {
+			case "TableName":
+				p.tableNames[recv] = literalReturn(d)
+			case "Scan", "Value":
+				p.scanners[recv] = true
+			}
+		}
+	}
+}
+
+func receiverName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// literalReturn 方法体只有一句 return "xxx" 的时候返回 xxx
+func literalReturn(fn *ast.FuncDecl) *string {
+	if fn.Body == nil || len(fn.Body.List) != 1 {
+		return nil
+	}
+	ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return nil
+	}
+	lit, ok := ret.Results[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil
+	}
+	val, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return nil
+	}
+	return &val
+}
+
+func (p *pkgInfo) model(name string) (modelInfo, error) {
+	st, ok := p.structs[name]
+	if !ok {
+		return modelInfo{}, fmt.Errorf("找不到结构体 %s", name)
+	}
+	tableName := model.UnderscoreName(name)
+	if tbl, ok := p.tableNames[name]; ok {
+		// 生成代码的时候没法调用方法，所以只支持直接返回字面量的写法
+		if tbl == nil {
+			return modelInfo{}, fmt.Errorf("%s 的 TableName 方法必须直接返回字符串字面量", name)
+		}
+		if *tbl != "" {
+			tableName = *tbl
+		}
+	}
+	fds, err := p.fields(tableName, st, nil, 0)
+	if err != nil {
+		return modelInfo{}, err
+	}
+	depths := make(map[string]int, len(fds))
+	for _, fd := range fds {
+		if d, ok := depths[fd.GoName]; !ok || fd.depth < d {
+			depths[fd.GoName] = fd.depth
+		}
+	}
+	res := modelInfo{
+		Name:       name,
+		ValuerName: lowerFirst(name) + "Valuer",
+		TableName:  tableName,
+	}
+	goNames := make(map[string]bool, len(fds))
+	for _, fd := range fds {
+		if fd.depth != depths[fd.GoName] {
+			continue
+		}
+		if goNames[fd.GoName] {
+			return modelInfo{}, errs.NewErrAmbiguousField(fd.GoName)
+		}
+		goNames[fd.GoName] = true
+		res.Fields = append(res.Fields, fd.fieldInfo)
+	}
+	if len(res.Fields) == 0 {
+		return modelInfo{}, fmt.Errorf("%s 没有任何列", name)
+	}
+	return res, nil
+}
+
+// fields 解析 st 的字段，组合进来的同一个包里面的结构体会被展开，
+// path 是 st 相对于最外层结构体的访问路径
+func (p *pkgInfo) fields(tableName string, st *ast.StructType, path []string, depth int) ([]fieldWithDepth, error) {
+	res := make([]fieldWithDepth, 0, len(st.Fields.List))
+	for _, f := range st.Fields.List {
+		tag := ""
+		if f.Tag != nil {
+			tag, _ = strconv.Unquote(f.Tag.Value)
+		}
+		names := make([]string, 0, len(f.Names))
+		for _, n := range f.Names {
+			names = append(names, n.Name)
+		}
+		if len(f.Names) == 0 {
+			embedded, err := p.embedded(tableName, f, reflect.StructTag(tag), path, depth)
+			if err != nil {
+				return nil, err
+			}
+			if embedded != nil {
+				res = append(res, embedded...)
+				continue
+			}
+			names = append(names, embeddedName(f.Type))
+		}
+		for _, name := range names {
+			fd, err := model.NewField(tableName, name, reflect.StructTag(tag))
+			if err != nil {
+				return nil, err
+			}
+			if fd == nil {
+				continue
+			}
+			res = append(res, fieldWithDepth{
+				fieldInfo: newFieldInfo(fd, append(path[:len(path):len(path)], name)),
+				depth:     depth,
+			})
+		}
+	}
+	return res, nil
+}
+
+// embedded 展开组合进来的结构体，返回 nil 代表它要当成一列
+func (p *pkgInfo) embedded(tableName string, f *ast.Field, tag reflect.StructTag, path []string, depth int) ([]fieldWithDepth, error) {
+	// 忽略的字段不管是什么类型都直接跳过，例如 sync.Mutex `orm:"-"`
+	if tag.Get("orm") == "-" {
+		return []fieldWithDepth{}, nil
+	}
+	if star, ok := f.Type.(*ast.StarExpr); ok {
+		if sel, ok := star.X.(*ast.SelectorExpr); ok {
+			return nil, p.checkForeign(sel)
+		}
+		if _, ok := p.structs[receiverName(star.X)]; ok {
+			// 指针没法通过偏移量计算地址
+			return nil, errs.NewErrEmbeddedPointer(receiverName(star.X))
+		}
+		return nil, nil
+	}
+	if sel, ok := f.Type.(*ast.SelectorExpr); ok {
+		return nil, p.checkForeign(sel)
+	}
+	ident, ok := f.Type.(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	st, ok := p.structs[ident.Name]
+	if !ok || p.scanners[ident.Name] {
+		return nil, nil
+	}
+	return p.fields(tableName, st, append(path[:len(path):len(path)], ident.Name), depth+1)
+}
+
+// checkForeign 别的包里面的类型没办法展开，
+// 除了 time.Time 这种确定是一列的，其余的都要求显式地给一个字段名
+func (p *pkgInfo) checkForeign(sel *ast.SelectorExpr) error {
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	if columnTypes[p.imports[pkg.Name]+"."+sel.Sel.Name] {
+		return nil
+	}
+	return fmt.Errorf("不支持组合别的包里面的类型 %s.%s，没有类型信息不知道要不要展开，请给它一个字段名",
+		pkg.Name, sel.Sel.Name)
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
+
+func newFieldInfo(fd *model.Field, path []string) fieldInfo {
+	offsets := make([]string, 0, len(path))
+	for i := range path {
+		offsets = append(offsets, "unsafe.Offsetof(val."+strings.Join(path[:i+1], ".")+")")
+	}
+	var attrs []string
+	if fd.PrimaryKey {
+		attrs = append(attrs, "PrimaryKey: true")
+	}
+	if fd.AutoIncrement {
+		attrs = append(attrs, "AutoIncrement: true")
+	}
+	if fd.Nullable {
+		attrs = append(attrs, "Nullable: true")
+	}
+	if fd.Default != "" {
+		attrs = append(attrs, "Default: "+strconv.Quote(fd.Default))
+	}
+	if fd.Size != 0 {
+		attrs = append(attrs, "Size: "+strconv.Itoa(fd.Size))
+	}
+	if fd.SQLType != "" {
+		attrs = append(attrs, "SQLType: "+strconv.Quote(fd.SQLType))
+	}
+	if fd.Index != "" {
+		attrs = append(attrs, "Index: "+strconv.Quote(fd.Index))
+	}
+	if fd.Unique != "" {
+		attrs = append(attrs, "Unique: "+strconv.Quote(fd.Unique))
+	}
+	return fieldInfo{
+		GoName:  fd.GoName,
+		ColName: fd.ColName,
+		Path:    strings.Join(path, "."),
+		Offset:  strings.Join(offsets, " + "),
+		Attrs:   attrs,
+	}
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	rs := []rune(s)
+	rs[0] = unicode.ToLower(rs[0])
+	return string(rs)
+}
+
+var genTpl = template.Must(template.New("ormgen").Parse(`// Code generated by ormgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"reflect"
+	"unsafe"
+)
+{{range .Models}}
+// {{.Name}}Columns 是 {{.Name}} 的列，用它代替 orm.C("FieldName")，写错了编译就过不了
+var {{.Name}}Columns = struct {
+{{- range .Fields}}
+	{{.GoName}} orm.Column
+{{- end}}
+}{
+{{- range .Fields}}
+	{{.GoName}}: orm.C({{printf "%q" .GoName}}),
+{{- end}}
+}
+
+// OrmModel 返回 {{.Name}} 的元数据，注册的时候不需要再解析结构体
+func (*{{.Name}}) OrmModel() (*model.Model, error) {
+	var val {{.Name}}
+	return model.NewModel({{printf "%q" .TableName}}, []*model.Field{
+{{- range .Fields}}
+		{
+			GoName:  {{printf "%q" .GoName}},
+			ColName: {{printf "%q" .ColName}},
+			Type:    reflect.TypeOf(val.{{.Path}}),
+			Offset:  {{.Offset}},
+{{- range .Attrs}}
+			{{.}},
+{{- end}}
+		},
+{{- end}}
+	})
+}
+
+// OrmValue 返回读写 {{.Name}} 字段的 orm.Valuer，不需要反射也不需要 unsafe
+func (e *{{.Name}}) OrmValue(m *model.Model, ignoreUnknown bool) orm.Valuer {
+	return {{.ValuerName}}{
+		model:         m,
+		val:           e,
+		ignoreUnknown: ignoreUnknown,
+	}
+}
+
+type {{.ValuerName}} struct {
+	model         *model.Model
+	val           *{{.Name}}
+	ignoreUnknown bool
+}
+
+func (v {{.ValuerName}}) Field(name string) (any, error) {
+	switch name {
+{{- range .Fields}}
+	case {{printf "%q" .GoName}}:
+		return v.val.{{.Path}}, nil
+{{- end}}
+	}
+	return nil, orm.NewErrUnknownField(name)
+}
+
+func (v {{.ValuerName}}) SetField(name string, val any) error {
+	switch name {
+{{- range .Fields}}
+	case {{printf "%q" .GoName}}:
+		return orm.SetFieldValue(&v.val.{{.Path}}, val)
+{{- end}}
+	}
+	return orm.NewErrUnknownField(name)
+}
+
+func (v {{.ValuerName}}) SetColumns(rows *sql.Rows) error {
+	cs, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	vals := make([]any, 0, len(cs))
+	for _, c := range cs {
+		fd, ok := v.model.ColumnMap[c]
+		if !ok {
+			if !v.ignoreUnknown {
+				return orm.NewErrUnknownColumn(c)
+			}
+			vals = append(vals, new(any))
+			continue
+		}
+		switch fd.GoName {
+{{- range .Fields}}
+		case {{printf "%q" .GoName}}:
+			vals = append(vals, &v.val.{{.Path}})
+{{- end}}
+		default:
+			return orm.NewErrUnknownField(fd.GoName)
+		}
+	}
+	return rows.Scan(vals...)
+}
+{{end}}`))
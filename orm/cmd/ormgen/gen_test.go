@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 生成的代码改了之后记得在 example 目录下执行 go generate
+func TestGenerate_Example(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("example", "user_orm_gen.go"))
+	require.NoError(t, err)
+	got, err := generate("example", []string{"User", "Order"})
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		src     string
+		typ     string
+		wantErr error
+		// wantContains 生成的代码里面应该有的片段
+		wantContains []string
+	}{
+		{
+			name:    "unknown type",
+			src:     `package a`,
+			typ:     "User",
+			wantErr: errors.New("找不到结构体 User"),
+		},
+		{
+			name: "table name not literal",
+			src: `package a
+type User struct { Id int }
+func (User) TableName() string { return prefix + "user" }`,
+			typ:     "User",
+			wantErr: errors.New("User 的 TableName 方法必须直接返回字符串字面量"),
+		},
+		{
+			name: "embedded pointer",
+			src: `package a
+type Base struct { Id int }
+type User struct { *Base }`,
+			typ:     "User",
+			wantErr: errs.NewErrEmbeddedPointer("Base"),
+		},
+		{
+			name: "embedded foreign type",
+			src: `package a
+import "example.com/base"
+type User struct { base.Model }`,
+			typ:     "User",
+			wantErr: errors.New("不支持组合别的包里面的类型 base.Model，没有类型信息不知道要不要展开，请给它一个字段名"),
+		},
+		{
+			name: "embedded foreign pointer",
+			src: `package a
+import b "example.com/base"
+type User struct { *b.Model }`,
+			typ:     "User",
+			wantErr: errors.New("不支持组合别的包里面的类型 b.Model，没有类型信息不知道要不要展开，请给它一个字段名"),
+		},
+		{
+			name: "ambiguous field",
+			src: `package a
+type A struct { Id int }
+type B struct { Id int }
+type User struct { A; B }`,
+			typ:     "User",
+			wantErr: errs.NewErrAmbiguousField("Id"),
+		},
+		{
+			name: "invalid tag",
+			src: `package a
+type User struct { Id int ` + "`orm:\"abc\"`" + ` }`,
+			typ:     "User",
+			wantErr: errs.NewErrInvalidTagContent("abc"),
+		},
+		{
+			name: "no columns",
+			src: `package a
+type User struct { Id int ` + "`orm:\"-\"`" + ` }`,
+			typ:     "User",
+			wantErr: errors.New("User 没有任何列"),
+		},
+		{
+			name: "shadowed and ignored",
+			src: `package a
+import (
+	"database/sql"
+	"time"
+)
+type Base struct { Id int; Name string }
+type Ignored struct { Remark string }
+type Time struct { Unix int64 }
+type Deleted struct { *sql.NullTime }
+func (t *Time) Scan(src any) error { return nil }
+type user struct {
+	Base
+	Ignored ` + "`orm:\"-\"`" + `
+	time.Time
+	Created Time
+	Deleted
+	Name string ` + "`orm:\"column=user_name\"`" + `
+}
+func (*user) TableName() string { return "" }`,
+			typ: "user",
+			wantContains: []string{
+				`var userColumns = struct {`,
+				`return model.NewModel("user", []*model.Field{`,
+				`Offset:  unsafe.Offsetof(val.Base) + unsafe.Offsetof(val.Base.Id),`,
+				`Type:    reflect.TypeOf(val.Time),`,
+				`Type:    reflect.TypeOf(val.Created),`,
+				`Type:    reflect.TypeOf(val.Deleted.NullTime),`,
+				`ColName: "user_name",`,
+				`type userValuer struct {`,
+			},
+		},
+		{
+			// 忽略的字段不用展开，所以指针和别的包里面的类型也没问题
+			name: "ignored embedded",
+			src: `package a
+import (
+	"sync"
+	b "example.com/base"
+)
+type Base struct { Id int }
+type User struct {
+	sync.Mutex ` + "`orm:\"-\"`" + `
+	*Base ` + "`orm:\"-\"`" + `
+	*b.Model ` + "`orm:\"-\"`" + `
+	Id int
+}`,
+			typ: "User",
+			wantContains: []string{
+				`Offset:  unsafe.Offsetof(val.Id),`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte(tc.src), 0644))
+			src, err := generate(dir, []string{tc.typ})
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			for _, s := range tc.wantContains {
+				assert.Contains(t, string(src), s)
+			}
+			// 覆盖掉的和忽略的字段都不应该出现
+			assert.NotContains(t, string(src), "val.Base.Name")
+			assert.NotContains(t, string(src), "Remark")
+			assert.NotContains(t, string(src), "Unix")
+			assert.NotContains(t, string(src), "Mutex")
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// ormgen 根据模型的定义生成列、元数据和读写字段的代码，
+// 生成的代码不需要反射，写错了列名编译的时候就能发现。
+//
+// 在模型所在的文件里面加上：
+//
+//	//go:generate go run gitee.com/geektime-geekbang/geektime-go/orm/cmd/ormgen -type=User,Order
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ormgen: ")
+	typeNames := flag.String("type", "", "要生成代码的结构体，多个用逗号分隔")
+	output := flag.String("output", "", "输出文件，默认是 <第一个结构体>_orm_gen.go")
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	types := strings.Split(*typeNames, ",")
+	dir := "."
+	if args := flag.Args(); len(args) > 0 {
+		dir = args[0]
+	}
+
+	src, err := generate(dir, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := *output
+	if name == "" {
+		name = filepath.Join(dir, outputName(types[0]))
+	}
+	if err = os.WriteFile(name, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
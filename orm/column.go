@@ -1,6 +1,10 @@
 package orm
 
+import "reflect"
+
 type Column struct {
+	// table 为 nil 的时候，代表的是 Selector[T] 中 T 对应的表
+	table TableReference
 	name string
 	alias string
 }
@@ -13,6 +17,7 @@ func (c Column) assign() {}
 
 func (c Column) As(alias string) Column {
 	return Column{
+		table: c.table,
 		name: c.name,
 		alias: alias,
 	}
@@ -29,6 +34,167 @@ func (c Column) Eq(arg any) Predicate {
 	}
 }
 
+// InQuery 代表 IN 子查询
+// C("Id").InQuery(sub)
+func (c Column) InQuery(sub Subquery) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opIn,
+		right: sub,
+	}
+}
+
+// Add 代表加法
+// C("Age").Add(1)
+func (c Column) Add(delta any) MathExpr {
+	return MathExpr{
+		left:  c,
+		op:    opAdd,
+		right: valueOf(delta),
+	}
+}
+
+// Multi 代表乘法
+// C("Age").Multi(2)
+func (c Column) Multi(delta any) MathExpr {
+	return MathExpr{
+		left:  c,
+		op:    opMulti,
+		right: valueOf(delta),
+	}
+}
+
+// Sub 代表减法
+// C("Age").Sub(1)
+func (c Column) Sub(delta any) MathExpr {
+	return MathExpr{
+		left:  c,
+		op:    opSub,
+		right: valueOf(delta),
+	}
+}
+
+// Div 代表除法
+// C("Age").Div(2)
+func (c Column) Div(delta any) MathExpr {
+	return MathExpr{
+		left:  c,
+		op:    opDiv,
+		right: valueOf(delta),
+	}
+}
+
+// NotEq 代表不等于
+// C("id").NotEq(12)
+func (c Column) NotEq(arg any) Predicate {
+	return c.binary(opNotEq, arg)
+}
+
+// Gt 代表大于
+func (c Column) Gt(arg any) Predicate {
+	return c.binary(opGT, arg)
+}
+
+// Gte 代表大于等于
+func (c Column) Gte(arg any) Predicate {
+	return c.binary(opGTE, arg)
+}
+
+// Lt 代表小于
+func (c Column) Lt(arg any) Predicate {
+	return c.binary(opLT, arg)
+}
+
+// Lte 代表小于等于
+func (c Column) Lte(arg any) Predicate {
+	return c.binary(opLTE, arg)
+}
+
+// Like C("FirstName").Like("Tom%")
+func (c Column) Like(pattern string) Predicate {
+	return c.binary(opLike, pattern)
+}
+
+func (c Column) NotLike(pattern string) Predicate {
+	return c.binary(opNotLike, pattern)
+}
+
+// In 代表 IN 查询
+// C("Id").In(1, 2, 3)
+// C("Id").In([]int{1, 2, 3}) 切片会被展开
+// 如果没有传入任何值，那么这个条件永远不成立
+func (c Column) In(vals ...any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opIn,
+		right: values{vals: flatten(vals)},
+	}
+}
+
+// NotIn 代表 NOT IN 查询，用法和 In 一样
+// 如果没有传入任何值，那么这个条件永远成立
+func (c Column) NotIn(vals ...any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opNotIn,
+		right: values{vals: flatten(vals)},
+	}
+}
+
+// Between 代表 BETWEEN low AND high
+func (c Column) Between(low, high any) Predicate {
+	return Predicate{
+		left: c,
+		op:   opBetween,
+		right: betweenRange{
+			low:  valueOf(low),
+			high: valueOf(high),
+		},
+	}
+}
+
+func (c Column) IsNull() Predicate {
+	return Predicate{
+		left: c,
+		op:   opIsNull,
+	}
+}
+
+func (c Column) IsNotNull() Predicate {
+	return Predicate{
+		left: c,
+		op:   opIsNotNull,
+	}
+}
+
+func (c Column) binary(o op, arg any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    o,
+		right: valueOf(arg),
+	}
+}
+
+// flatten 如果只传入了一个切片，那么把切片展开
+// []byte 会被当成一个值
+func flatten(vals []any) []any {
+	if len(vals) != 1 {
+		return vals
+	}
+	if _, ok := vals[0].([]byte); ok {
+		return vals
+	}
+	rv := reflect.ValueOf(vals[0])
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return vals
+	}
+	res := make([]any, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		res = append(res, rv.Index(i).Interface())
+	}
+	return res
+}
+
 func valueOf(arg any) Expression {
 	switch val := arg.(type) {
 	case Expression:
@@ -1,19 +1,21 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
 	"gitee.com/geektime-geekbang/geektime-go/orm/internal/valuer"
 	"gitee.com/geektime-geekbang/geektime-go/orm/model"
 )
 
 type DBOption func(db *DB)
 
+var _ Session = &DB{}
+
 // DB 是一个 sql.DB 的装饰器
 type DB struct {
-	r model.Registry
+	core
 	db *sql.DB
-	creator valuer.Creator
-	dialect Dialect
 }
 
 func Open(driver string, dataSourceName string, opts...DBOption) (*DB, error) {
@@ -26,10 +28,12 @@ func Open(driver string, dataSourceName string, opts...DBOption) (*DB, error) {
 
 func OpenDB(db *sql.DB, opts...DBOption) (*DB, error) {
 	res := &DB{
-		r:  model.NewRegistry(),
+		core: core{
+			r:       model.NewRegistry(),
+			creator: valuer.NewUnsafeValue,
+			dialect: DialectMySQL,
+		},
 		db: db,
-		creator: valuer.NewUnsafeValue,
-		dialect: DialectMySQL,
 	}
 	for _, opt := range opts {
 		opt(res)
@@ -49,12 +53,27 @@ func DBWithRegistry(r model.Registry) DBOption {
 	}
 }
 
+// DBWithMiddlewares 设置 middleware，按照传入的顺序执行
+func DBWithMiddlewares(mdls ...Middleware) DBOption {
+	return func(db *DB) {
+		db.mdls = mdls
+	}
+}
+
 func DBUseReflect() DBOption {
 	return func(db *DB) {
 		db.creator = valuer.NewReflectValue
 	}
 }
 
+// DBIgnoreUnknownColumns 查询结果里面有模型没有的列的时候，忽略这些列，
+// 默认情况下会返回错误
+func DBIgnoreUnknownColumns() DBOption {
+	return func(db *DB) {
+		db.ignoreUnknownColumns = true
+	}
+}
+
 func MustOpen(driver string, dataSourceName string, opts...DBOption) *DB {
 	res, err := Open(driver, dataSourceName, opts...)
 	if err != nil {
@@ -62,3 +81,52 @@ func MustOpen(driver string, dataSourceName string, opts...DBOption) *DB {
 	}
 	return res
 }
+
+func (db *DB) getCore() core {
+	return db.core
+}
+
+func (db *DB) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.db.QueryContext(ctx, query, args...)
+}
+
+func (db *DB) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.db.ExecContext(ctx, query, args...)
+}
+
+// BeginTx 开启一个事务
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tx{tx: tx}
+	t.core = db.core
+	t.core.tx = t
+	return t, nil
+}
+
+// DoTx 在事务中执行 fn
+// fn 返回 nil 则提交事务，返回 error 则回滚事务，
+// fn 发生 panic 的时候，会先回滚事务，再把 panic 继续抛出去
+func (db *DB) DoTx(ctx context.Context,
+	fn func(ctx context.Context, tx *Tx) error,
+	opts *sql.TxOptions) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err = fn(ctx, tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errs.NewErrFailToRollbackTx(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,100 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+)
+
+// Deleter 用于构造 DELETE 语句
+type Deleter[T any] struct {
+	builder
+	sess  Session
+	table TableReference
+	where []Predicate
+	// allowNoWhere 为 true 的时候才允许构造不带 WHERE 的 DELETE 语句
+	allowNoWhere bool
+	returning    []string
+}
+
+func NewDeleter[T any](sess Session) *Deleter[T] {
+	return &Deleter[T]{
+		builder: newBuilder(sess),
+		sess:    sess,
+	}
+}
+
+// From 指定表，例如 TableOf(&User{}).In("db")，不调用的话就是 T 对应的表
+func (d *Deleter[T]) From(table Table) *Deleter[T] {
+	d.table = table
+	return d
+}
+
+func (d *Deleter[T]) Where(ps ...Predicate) *Deleter[T] {
+	d.where = ps
+	return d
+}
+
+// AllowNoWhere 允许不带 WHERE 条件的 DELETE 语句，也就是删除全表数据
+// 默认情况下，Build 会拒绝这种语句，防止误删数据
+func (d *Deleter[T]) AllowNoWhere() *Deleter[T] {
+	d.allowNoWhere = true
+	return d
+}
+
+// Returning 指定 RETURNING 的列，传入的是字段名
+// MySQL 不支持 RETURNING
+func (d *Deleter[T]) Returning(cols ...string) *Deleter[T] {
+	d.returning = cols
+	return d
+}
+
+func (d *Deleter[T]) Build() (*Query, error) {
+	if len(d.where) == 0 && !d.allowNoWhere {
+		return nil, errs.ErrDeleteWithoutWhere
+	}
+	// 和 Updater 一样，Build 可能被调用多次
+	d.sb.Reset()
+	d.args = nil
+	d.argCols = nil
+	d.tables = nil
+	var err error
+	d.model, err = d.r.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	d.sb.WriteString("DELETE FROM ")
+	if err = d.buildTable(d.table); err != nil {
+		return nil, err
+	}
+
+	if len(d.where) > 0 {
+		d.sb.WriteString(" WHERE ")
+		if err = d.buildPredicates(d.where); err != nil {
+			return nil, err
+		}
+	}
+	if len(d.returning) > 0 {
+		if err = d.dialect.buildReturning(&d.builder, d.returning); err != nil {
+			return nil, err
+		}
+	}
+	d.sb.WriteByte(';')
+	return d.buildQuery(), nil
+}
+
+func (d *Deleter[T]) Exec(ctx context.Context) Result {
+	q, err := d.Build()
+	if err != nil {
+		return Result{
+			err: err,
+		}
+	}
+	return exec(ctx, d.sess, &QueryContext{
+		Type:       "DELETE",
+		Builder:    d,
+		Model:      d.model,
+		Query:      q,
+		ArgColumns: d.argCols,
+		Tables:     d.tables,
+	})
+}
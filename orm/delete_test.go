@@ -0,0 +1,148 @@
+package orm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDeleter_Build(t *testing.T) {
+	db := memoryDB(t)
+	type OrderDetail struct {
+		OrderId int
+	}
+	testCases := []struct {
+		name string
+		d    QueryBuilder
+
+		wantErr   error
+		wantQuery *Query
+	}{
+		{
+			name:    "no where",
+			d:       NewDeleter[TestModel](db),
+			wantErr: errs.ErrDeleteWithoutWhere,
+		},
+		{
+			name:    "empty where",
+			d:       NewDeleter[TestModel](db).Where(),
+			wantErr: errs.ErrDeleteWithoutWhere,
+		},
+		{
+			name: "allow no where",
+			d:    NewDeleter[TestModel](db).AllowNoWhere(),
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model`;",
+			},
+		},
+		{
+			name: "where",
+			d:    NewDeleter[TestModel](db).Where(C("Id").Eq(16)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `id` = ?;",
+				Args: []any{16},
+			},
+		},
+		{
+			name: "from",
+			d:    NewDeleter[TestModel](db).From(TableOf(&TestModel{}).In("test_db")).Where(C("Id").Eq(16)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_db`.`test_model` WHERE `id` = ?;",
+				Args: []any{16},
+			},
+		},
+		{
+			name: "from other table",
+			d: func() QueryBuilder {
+				t := TableOf(&OrderDetail{})
+				return NewDeleter[TestModel](db).From(t).Where(t.C("OrderId").Eq(16))
+			}(),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `order_detail` WHERE `order_detail`.`order_id` = ?;",
+				Args: []any{16},
+			},
+		},
+		{
+			name: "and",
+			d:    NewDeleter[TestModel](db).Where(C("Id").Eq(16), C("Age").Eq(18)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE (`id` = ?) AND (`age` = ?);",
+				Args: []any{16, 18},
+			},
+		},
+		{
+			name:    "invalid column",
+			d:       NewDeleter[TestModel](db).Where(C("Invalid").Eq(16)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.d.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+			// 再 Build 一次，结果是一样的
+			q, err = tc.d.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestDeleter_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	testCases := []struct {
+		name     string
+		d        *Deleter[TestModel]
+		wantErr  error
+		affected int64
+	}{
+		{
+			name:    "query error",
+			d:       NewDeleter[TestModel](db),
+			wantErr: errs.ErrDeleteWithoutWhere,
+		},
+		{
+			name: "db error",
+			d: func() *Deleter[TestModel] {
+				mock.ExpectExec("DELETE .*").
+					WillReturnError(errors.New("db error"))
+				return NewDeleter[TestModel](db).Where(C("Id").Eq(1))
+			}(),
+			wantErr: errors.New("db error"),
+		},
+		{
+			name: "exec",
+			d: func() *Deleter[TestModel] {
+				res := driver.RowsAffected(1)
+				mock.ExpectExec("DELETE .*").
+					WillReturnResult(res)
+				return NewDeleter[TestModel](db).Where(C("Id").Eq(1))
+			}(),
+			affected: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.d.Exec(context.Background())
+			affected, err := res.RowsAffected()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.affected, affected)
+		})
+	}
+}
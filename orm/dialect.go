@@ -2,10 +2,15 @@ package orm
 
 import (
 	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"strconv"
+	"strings"
 )
 
 var (
 	DialectMySQL Dialect = mysqlDialect{}
+	// DialectMySQL8 使用 MySQL 8.0.19 引入的行别名来引用准备插入的行，
+	// 代替已经废弃的 VALUES(col)
+	DialectMySQL8 Dialect = mysqlDialect{rowAlias: "new"}
 	DialectSQLite Dialect = sqliteDialect{}
 	DialectPostgreSQL Dialect = postgreDialect{}
 )
@@ -15,62 +20,203 @@ type Dialect interface {
 	// MySQL `
 	quoter() byte
 
+	// buildInsertInto 构造 INSERT INTO 部分，MySQL 的 DO NOTHING 是 INSERT IGNORE
+	buildInsertInto(b *builder, upsert *Upsert)
+
 	buildUpsert(b *builder, upsert *Upsert) error
+
+	// buildExcluded 引用 UPSERT 的时候准备插入的那一行的 col 列
+	buildExcluded(b *builder, col string)
+
+	// buildLimit 构造 LIMIT 和 OFFSET 部分，limit 或者 offset 为 0 代表没有设置
+	buildLimit(b *builder, limit int, offset int)
+
+	// buildReturning 构造 RETURNING 部分
+	buildReturning(b *builder, cols []string) error
+
+	// rebind 把构造过程中使用的 ? 占位符改写成方言自己的占位符
+	// PostgreSQL $1, $2...
+	rebind(query string) string
+
+	// insertIDs 根据 LastInsertId 推算一次插入 n 行的每一行的自增主键，
+	// 返回 false 代表不支持，要通过 RETURNING 来获取
+	insertIDs(lastID int64, n int) ([]int64, bool)
+
+	// maxArgs 一条语句里面最多能有多少个参数，分批插入的时候用
+	maxArgs() int
+}
+
+// Quote 用方言的引号把标识符括起来，migrate 之类生成 SQL 的包用
+func Quote(d Dialect, name string) string {
+	q := string(d.quoter())
+	return q + name + q
 }
 
+// Rebind 把 ? 占位符改写成方言自己的占位符
+func Rebind(d Dialect, query string) string {
+	return d.rebind(query)
+}
+
+// standardSQL 是按照 SQL 标准来实现的，
+// 标识符用双引号，UPSERT 用 ON CONFLICT ... DO UPDATE
 type standardSQL struct {
 
 }
 
 func (s standardSQL) quoter() byte {
-	// TODO implement me
-	panic("implement me")
+	return '"'
+}
+
+func (s standardSQL) buildInsertInto(b *builder, upsert *Upsert) {
+	b.sb.WriteString("INSERT INTO ")
 }
 
 func (s standardSQL) buildUpsert(b *builder, upsert *Upsert) error {
-	// TODO implement me
-	panic("implement me")
+	if upsert.doNothing {
+		b.sb.WriteString(" ON CONFLICT")
+		if len(upsert.conflictColumns) > 0 {
+			b.sb.WriteByte(' ')
+			if err := b.buildConflictColumns(upsert.conflictColumns); err != nil {
+				return err
+			}
+		}
+		b.sb.WriteString(" DO NOTHING")
+		return nil
+	}
+	// DO UPDATE 必须指定冲突的列
+	if len(upsert.conflictColumns) == 0 {
+		return errs.ErrUpsertWithoutConflictColumns
+	}
+	b.sb.WriteString(" ON CONFLICT ")
+	if err := b.buildConflictColumns(upsert.conflictColumns); err != nil {
+		return err
+	}
+	b.sb.WriteString(" DO UPDATE SET ")
+	// SET 和 WHERE 里面不带表名的列会有歧义，不知道是原本的行还是 EXCLUDED
+	b.tablePrefix = b.model.TableName
+	defer func() {
+		b.tablePrefix = ""
+	}()
+	return b.buildUpsertUpdate(upsert)
+}
+
+func (s standardSQL) buildExcluded(b *builder, col string) {
+	b.sb.WriteString("EXCLUDED.")
+	b.quote(col)
+}
+
+func (s standardSQL) buildReturning(b *builder, cols []string) error {
+	b.sb.WriteString(" RETURNING ")
+	for i, col := range cols {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildColumn(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s standardSQL) rebind(query string) string {
+	return query
+}
+
+func (s standardSQL) insertIDs(lastID int64, n int) ([]int64, bool) {
+	return nil, false
+}
+
+// maxArgs 不知道具体是什么数据库，保守一点
+func (s standardSQL) maxArgs() int {
+	return 999
+}
+
+func (s standardSQL) buildLimit(b *builder, limit int, offset int) {
+	if limit > 0 {
+		b.sb.WriteString(" LIMIT ?")
+		b.addArg(limit)
+	}
+	if offset > 0 {
+		b.sb.WriteString(" OFFSET ?")
+		b.addArg(offset)
+	}
 }
 
 type mysqlDialect struct {
 	standardSQL
+	// rowAlias 不为空的时候用行别名引用准备插入的行，
+	// 也就是 VALUES (...) AS new ON DUPLICATE KEY UPDATE `col`=`new`.`col`
+	rowAlias string
 }
 
 func (s mysqlDialect) quoter() byte {
 	return '`'
 }
 
+func (s mysqlDialect) buildInsertInto(b *builder, upsert *Upsert) {
+	if upsert != nil && upsert.doNothing {
+		b.sb.WriteString("INSERT IGNORE INTO ")
+		return
+	}
+	b.sb.WriteString("INSERT INTO ")
+}
+
 func (s mysqlDialect) buildUpsert(b *builder, upsert *Upsert) error {
-	b.sb.WriteString(" ON DUPLICATE KEY UPDATE ")
-	for idx, assign := range upsert.assigns {
-		if idx > 0 {
-			b.sb.WriteByte(',')
-		}
-		switch a := assign.(type) {
-		case Assignment:
-			fd, ok := b.model.FieldMap[a.col]
-			// 字段不对，或者说列不对
-			if !ok {
-				return errs.NewErrUnknownField(a.col)
-			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=?")
-			b.addArg(a.val)
-		case Column:
-			fd, ok := b.model.FieldMap[a.name]
-			// 字段不对，或者说列不对
-			if !ok {
-				return errs.NewErrUnknownField(a.name)
-			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=VALUES(")
-			b.quote(fd.ColName)
-			b.sb.WriteByte(')')
-		default:
-			return errs.NewErrUnsupportedAssignable(assign)
+	if upsert.doNothing {
+		// INSERT IGNORE 没办法指定冲突的列，任何唯一索引冲突都会被忽略
+		if len(upsert.conflictColumns) > 0 {
+			return errs.NewErrUnsupportedDialectFeature("MySQL", "DO NOTHING 指定冲突的列")
 		}
+		return nil
 	}
-	return nil
+	if len(upsert.where) > 0 {
+		return errs.NewErrUnsupportedDialectFeature("MySQL", "UPSERT WHERE")
+	}
+	if s.rowAlias != "" {
+		b.sb.WriteString(" AS ")
+		b.quote(s.rowAlias)
+	}
+	b.sb.WriteString(" ON DUPLICATE KEY UPDATE ")
+	return b.buildUpsertUpdate(upsert)
+}
+
+func (s mysqlDialect) buildExcluded(b *builder, col string) {
+	if s.rowAlias != "" {
+		b.quote(s.rowAlias)
+		b.sb.WriteByte('.')
+		b.quote(col)
+		return
+	}
+	b.sb.WriteString("VALUES(")
+	b.quote(col)
+	b.sb.WriteByte(')')
+}
+
+func (s mysqlDialect) buildLimit(b *builder, limit int, offset int) {
+	// MySQL 不支持单独的 OFFSET，只能用一个足够大的 LIMIT
+	if limit <= 0 && offset > 0 {
+		b.sb.WriteString(" LIMIT 18446744073709551615")
+	}
+	s.standardSQL.buildLimit(b, limit, offset)
+}
+
+func (s mysqlDialect) buildReturning(b *builder, cols []string) error {
+	return errs.NewErrUnsupportedDialectFeature("MySQL", "RETURNING")
+}
+
+// insertIDs MySQL 返回的是第一行的 ID，后面的行是连续的
+// 前提是 auto_increment_increment 是默认的 1
+func (s mysqlDialect) insertIDs(lastID int64, n int) ([]int64, bool) {
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = lastID + int64(i)
+	}
+	return ids, true
+}
+
+// maxArgs MySQL 的预编译语句最多 65535 个参数
+func (s mysqlDialect) maxArgs() int {
+	return 65535
 }
 
 type sqliteDialect struct {
@@ -82,48 +228,84 @@ func (s sqliteDialect) quoter() byte {
 }
 
 func (s sqliteDialect) buildUpsert(b *builder, upsert *Upsert) error {
-	b.sb.WriteString(" ON CONFLICT(")
-	for i, col := range upsert.conflictColumns {
-		if i > 0 {
-			b.sb.WriteByte(',')
-		}
-		err := b.buildColumn(col)
-		if err != nil {
+	if upsert.doNothing {
+		b.sb.WriteString(" ON CONFLICT")
+		if err := b.buildConflictColumns(upsert.conflictColumns); err != nil {
 			return err
 		}
+		b.sb.WriteString(" DO NOTHING")
+		return nil
 	}
-	b.sb.WriteString(") DO UPDATE SET ")
-	for idx, assign := range upsert.assigns {
-		if idx > 0 {
-			b.sb.WriteByte(',')
-		}
-		switch a := assign.(type) {
-		case Assignment:
-			fd, ok := b.model.FieldMap[a.col]
-			// 字段不对，或者说列不对
-			if !ok {
-				return errs.NewErrUnknownField(a.col)
-			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=?")
-			b.addArg(a.val)
-		case Column:
-			fd, ok := b.model.FieldMap[a.name]
-			// 字段不对，或者说列不对
-			if !ok {
-				return errs.NewErrUnknownField(a.name)
-			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=excluded.")
-			b.quote(fd.ColName)
-		default:
-			return errs.NewErrUnsupportedAssignable(assign)
-		}
+	if len(upsert.conflictColumns) == 0 {
+		return errs.ErrUpsertWithoutConflictColumns
 	}
-	return nil
+	b.sb.WriteString(" ON CONFLICT")
+	if err := b.buildConflictColumns(upsert.conflictColumns); err != nil {
+		return err
+	}
+	b.sb.WriteString(" DO UPDATE SET ")
+	return b.buildUpsertUpdate(upsert)
+}
+
+func (s sqliteDialect) buildExcluded(b *builder, col string) {
+	b.sb.WriteString("excluded.")
+	b.quote(col)
+}
+
+// maxArgs SQLite 3.32 之前 SQLITE_MAX_VARIABLE_NUMBER 默认是 999
+func (s sqliteDialect) maxArgs() int {
+	return 999
+}
+
+// insertIDs SQLite 返回的是最后一行的 rowid
+func (s sqliteDialect) insertIDs(lastID int64, n int) ([]int64, bool) {
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = lastID - int64(n-1-i)
+	}
+	return ids, true
 }
 
+func (s sqliteDialect) buildLimit(b *builder, limit int, offset int) {
+	// SQLite 的 OFFSET 必须跟在 LIMIT 后面，LIMIT -1 代表不限制
+	if limit <= 0 && offset > 0 {
+		b.sb.WriteString(" LIMIT -1")
+	}
+	s.standardSQL.buildLimit(b, limit, offset)
+}
 
 type postgreDialect struct {
 	standardSQL
-}
\ No newline at end of file
+}
+
+// maxArgs PostgreSQL 的协议里面参数个数是两个字节
+func (s postgreDialect) maxArgs() int {
+	return 65535
+}
+
+// rebind 把 ? 改写为 $1, $2...
+// 单引号括起来的字符串字面量和双引号括起来的标识符里面的 ? 不会被改写
+func (s postgreDialect) rebind(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	var quote byte
+	idx := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			idx++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(idx))
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,213 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPostgreSQL_Build(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(DialectPostgreSQL))
+	testCases := []struct {
+		name string
+		b    QueryBuilder
+
+		wantErr   error
+		wantQuery *Query
+	}{
+		{
+			name: "select",
+			b: NewSelector[TestModel](db).Select(C("Id"), Avg("Age").As("avg_age")).
+				Where(C("Age").Gt(18), C("FirstName").In("Tom", "Jerry")).
+				GroupBy(C("Id")).Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL: `SELECT "id",AVG("age") AS "avg_age" FROM "test_model" ` +
+					`WHERE ("age" > $1) AND ("first_name" IN ($2,$3)) GROUP BY "id" LIMIT $4 OFFSET $5;`,
+				Args: []any{18, "Tom", "Jerry", 10, 20},
+			},
+		},
+		{
+			name: "offset only",
+			b:    NewSelector[TestModel](db).Offset(20),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" OFFSET $1;`,
+				Args: []any{20},
+			},
+		},
+		{
+			name: "subquery",
+			b: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id")).
+					Where(C("Age").Gt(18)).AsSubquery("sub")
+				return NewSelector[TestModel](db).
+					Where(C("FirstName").Eq("Tom"), C("Id").InQuery(sub), C("Age").Lt(30))
+			}(),
+			wantQuery: &Query{
+				SQL: `SELECT * FROM "test_model" WHERE (("first_name" = $1) AND ` +
+					`("id" IN (SELECT "id" FROM "test_model" WHERE "age" > $2))) AND ("age" < $3);`,
+				Args: []any{"Tom", 18, 30},
+			},
+		},
+		{
+			name: "raw expression",
+			b:    NewSelector[TestModel](db).Where(Raw(`"first_name" = '?' AND "age" > ?`, 18).AsPredicate()),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE ("first_name" = '?' AND "age" > $1);`,
+				Args: []any{18},
+			},
+		},
+		{
+			name: "insert",
+			b: NewInserter[TestModel](db).Values(&TestModel{
+				Id:        12,
+				FirstName: "Tom",
+				Age:       18,
+				LastName:  &sql.NullString{String: "Jerry", Valid: true},
+			}),
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("id","first_name","age","last_name") VALUES ($1,$2,$3,$4);`,
+				Args: []any{int64(12), "Tom", int8(18), &sql.NullString{String: "Jerry", Valid: true}},
+			},
+		},
+		{
+			name: "upsert",
+			b: NewInserter[TestModel](db).Values(&TestModel{
+				Id:        12,
+				FirstName: "Tom",
+				Age:       18,
+				LastName:  &sql.NullString{String: "Jerry", Valid: true},
+			}).OnDuplicateKey().ConflictColumns("Id").
+				Update(C("FirstName"), Assign("Age", 19)),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model"("id","first_name","age","last_name") VALUES ($1,$2,$3,$4) ` +
+					`ON CONFLICT ("id") DO UPDATE SET "first_name"=EXCLUDED."first_name","age"=$5;`,
+				Args: []any{int64(12), "Tom", int8(18), &sql.NullString{String: "Jerry", Valid: true}, 19},
+			},
+		},
+		{
+			name: "upsert without conflict columns",
+			b: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnDuplicateKey().Update(C("FirstName")),
+			wantErr: errs.ErrUpsertWithoutConflictColumns,
+		},
+		{
+			name: "upsert do nothing",
+			b: NewInserter[TestModel](db).Columns("Id").Values(&TestModel{Id: 12}).
+				OnDuplicateKey().DoNothing(),
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("id") VALUES ($1) ON CONFLICT DO NOTHING;`,
+				Args: []any{int64(12)},
+			},
+		},
+		{
+			name: "upsert do nothing with conflict columns",
+			b: NewInserter[TestModel](db).Columns("Id").Values(&TestModel{Id: 12}).
+				OnDuplicateKey().ConflictColumns("Id").DoNothing(),
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("id") VALUES ($1) ON CONFLICT ("id") DO NOTHING;`,
+				Args: []any{int64(12)},
+			},
+		},
+		{
+			// 不带表名的列在 PostgreSQL 里面是有歧义的
+			name: "upsert expression and where",
+			b: NewInserter[TestModel](db).Columns("Id", "Age").Values(&TestModel{Id: 12, Age: 18}).
+				OnDuplicateKey().ConflictColumns("Id").Where(C("Age").Lt(Excluded("Age"))).
+				Update(Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model"("id","age") VALUES ($1,$2) ON CONFLICT ("id") ` +
+					`DO UPDATE SET "age"="test_model"."age" + EXCLUDED."age" WHERE "test_model"."age" < EXCLUDED."age";`,
+				Args: []any{int64(12), int8(18)},
+			},
+		},
+		{
+			name: "upsert excluded invalid column",
+			b: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnDuplicateKey().ConflictColumns("Id").Update(Assign("Age", Excluded("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "insert returning",
+			b: NewInserter[TestModel](db).Columns("FirstName").
+				Values(&TestModel{FirstName: "Tom"}).Returning("Id"),
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("first_name") VALUES ($1) RETURNING "id";`,
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name: "update",
+			b: NewUpdater[TestModel](db).Set(Assign("Age", C("Age").Add(1))).
+				Where(C("Id").Eq(12)).Returning("Id", "Age"),
+			wantQuery: &Query{
+				SQL:  `UPDATE "test_model" SET "age"="age" + $1 WHERE "id" = $2 RETURNING "id","age";`,
+				Args: []any{1, 12},
+			},
+		},
+		{
+			name: "delete",
+			b:    NewDeleter[TestModel](db).Where(C("Id").Eq(12)).Returning("FirstName"),
+			wantQuery: &Query{
+				SQL:  `DELETE FROM "test_model" WHERE "id" = $1 RETURNING "first_name";`,
+				Args: []any{12},
+			},
+		},
+		{
+			name:    "returning invalid column",
+			b:       NewDeleter[TestModel](db).Where(C("Id").Eq(12)).Returning("Invalid"),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.b.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestMySQL_Returning(t *testing.T) {
+	db := memoryDB(t)
+	_, err := NewDeleter[TestModel](db).Where(C("Id").Eq(12)).Returning("Id").Build()
+	assert.Equal(t, errs.NewErrUnsupportedDialectFeature("MySQL", "RETURNING"), err)
+}
+
+func TestPostgreSQL_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithDialect(DialectPostgreSQL))
+	require.NoError(t, err)
+
+	mock.ExpectExec(`UPDATE "test_model" SET "age"=$1 WHERE "id" = $2;`).
+		WithArgs(int8(18), 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	rows := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	rows.AddRow("12", "Tom", "18", "Jerry")
+	mock.ExpectQuery(`SELECT * FROM "test_model" WHERE "id" = $1;`).
+		WithArgs(12).
+		WillReturnRows(rows)
+
+	affected, err := NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).
+		Where(C("Id").Eq(12)).Exec(context.Background()).RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	res, err := NewSelector[TestModel](db).Where(C("Id").Eq(12)).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{
+		Id:        12,
+		FirstName: "Tom",
+		Age:       18,
+		LastName:  &sql.NullString{String: "Jerry", Valid: true},
+	}, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
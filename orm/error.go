@@ -1,6 +1,35 @@
 package orm
 
-import "gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+import (
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/valuer"
+)
 
 // 通过这种形式将内部错误，暴露在外面
-var ErrNoRows = errs.ErrNoRows
+var (
+	ErrNoRows = errs.ErrNoRows
+	// ErrNoUpdatedColumns UPDATE 语句没有任何需要更新的列
+	ErrNoUpdatedColumns = errs.ErrNoUpdatedColumns
+	// ErrUpsertWithoutConflictColumns ON CONFLICT ... DO UPDATE 没有指定冲突的列
+	ErrUpsertWithoutConflictColumns = errs.ErrUpsertWithoutConflictColumns
+	// ErrDeleteWithoutWhere DELETE 语句没有 WHERE 条件，又没有调用 AllowNoWhere
+	ErrDeleteWithoutWhere = errs.ErrDeleteWithoutWhere
+)
+
+// Valuer 是读写模型字段的抽象，ormgen 生成的代码会实现它
+type Valuer = valuer.Value
+
+// 下面这些是给 ormgen 生成的代码用的
+
+func NewErrUnknownField(name string) error {
+	return errs.NewErrUnknownField(name)
+}
+
+func NewErrUnknownColumn(name string) error {
+	return errs.NewErrUnknownColumn(name)
+}
+
+// SetFieldValue 把 val 设置到 dst 上，类型不一样的时候会做转换，例如 int64 转 int
+func SetFieldValue[T any](dst *T, val any) error {
+	return valuer.SetFieldValue(dst, val)
+}
@@ -27,3 +27,94 @@ func (r RawExpr) AsPredicate() Predicate {
 		left: r,
 	}
 }
+
+// ExcludedExpr 代表 UPSERT 的时候准备插入的那一行的某一列
+type ExcludedExpr struct {
+	name string
+}
+
+// Excluded 引用准备插入的那一行的 field 字段，只能在 UPSERT 里面使用
+// Assign("Count", C("Count").Add(Excluded("Count")))
+// MySQL 是 VALUES(`count`) 或者行别名 `new`.`count`，PostgreSQL 和 SQLite 是 EXCLUDED."count"
+func Excluded(field string) ExcludedExpr {
+	return ExcludedExpr{name: field}
+}
+
+func (e ExcludedExpr) expr() {}
+
+// MathExpr 代表算术表达式，可以用在 SELECT、WHERE、HAVING 和 SET 里面
+// C("Age").Add(1)
+// C("Price").Multi(C("Quantity")).As("total")
+type MathExpr struct {
+	left  Expression
+	op    op
+	right Expression
+	alias string
+}
+
+func (m MathExpr) Add(val any) MathExpr {
+	return m.math(opAdd, val)
+}
+
+func (m MathExpr) Sub(val any) MathExpr {
+	return m.math(opSub, val)
+}
+
+func (m MathExpr) Multi(val any) MathExpr {
+	return m.math(opMulti, val)
+}
+
+func (m MathExpr) Div(val any) MathExpr {
+	return m.math(opDiv, val)
+}
+
+func (m MathExpr) math(o op, val any) MathExpr {
+	// 别名只在最外层才有意义
+	m.alias = ""
+	return MathExpr{
+		left:  m,
+		op:    o,
+		right: valueOf(val),
+	}
+}
+
+// As 在 SELECT 里面使用的时候的别名
+func (m MathExpr) As(alias string) MathExpr {
+	m.alias = alias
+	return m
+}
+
+func (m MathExpr) Eq(arg any) Predicate {
+	return m.binary(opEq, arg)
+}
+
+func (m MathExpr) NotEq(arg any) Predicate {
+	return m.binary(opNotEq, arg)
+}
+
+func (m MathExpr) Gt(arg any) Predicate {
+	return m.binary(opGT, arg)
+}
+
+func (m MathExpr) Gte(arg any) Predicate {
+	return m.binary(opGTE, arg)
+}
+
+func (m MathExpr) Lt(arg any) Predicate {
+	return m.binary(opLT, arg)
+}
+
+func (m MathExpr) Lte(arg any) Predicate {
+	return m.binary(opLTE, arg)
+}
+
+func (m MathExpr) binary(o op, arg any) Predicate {
+	return Predicate{
+		left:  m,
+		op:    o,
+		right: valueOf(arg),
+	}
+}
+
+func (m MathExpr) expr() {}
+func (m MathExpr) selectable() {}
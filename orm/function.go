@@ -0,0 +1,127 @@
+package orm
+
+// FuncExpr 代表 SQL 函数调用，可以用在 SELECT、WHERE、HAVING 和 SET 里面
+// Fn("COALESCE", C("LastName"), "")
+// Lower(C("FirstName")).Eq("tom")
+type FuncExpr struct {
+	name  string
+	args  []Expression
+	alias string
+}
+
+// Fn 调用名字为 name 的函数，args 可以是表达式，也可以是普通的值。
+// name 会直接拼接到 SQL 里面，只能是字母、数字和下划线，否则构造的时候会返回错误
+func Fn(name string, args ...any) FuncExpr {
+	exprs := make([]Expression, 0, len(args))
+	for _, arg := range args {
+		exprs = append(exprs, valueOf(arg))
+	}
+	return FuncExpr{
+		name: name,
+		args: exprs,
+	}
+}
+
+func Lower(expr Expression) FuncExpr {
+	return Fn("LOWER", expr)
+}
+
+func Upper(expr Expression) FuncExpr {
+	return Fn("UPPER", expr)
+}
+
+func Length(expr Expression) FuncExpr {
+	return Fn("LENGTH", expr)
+}
+
+// Coalesce 返回第一个不是 NULL 的参数
+// Coalesce(C("LastName"), "")
+func Coalesce(args ...any) FuncExpr {
+	return Fn("COALESCE", args...)
+}
+
+// As 在 SELECT 里面使用的时候的别名
+func (f FuncExpr) As(alias string) FuncExpr {
+	f.alias = alias
+	return f
+}
+
+func (f FuncExpr) Add(val any) MathExpr {
+	return f.math(opAdd, val)
+}
+
+func (f FuncExpr) Sub(val any) MathExpr {
+	return f.math(opSub, val)
+}
+
+func (f FuncExpr) Multi(val any) MathExpr {
+	return f.math(opMulti, val)
+}
+
+func (f FuncExpr) Div(val any) MathExpr {
+	return f.math(opDiv, val)
+}
+
+func (f FuncExpr) math(o op, val any) MathExpr {
+	f.alias = ""
+	return MathExpr{
+		left:  f,
+		op:    o,
+		right: valueOf(val),
+	}
+}
+
+func (f FuncExpr) Eq(arg any) Predicate {
+	return f.binary(opEq, arg)
+}
+
+func (f FuncExpr) NotEq(arg any) Predicate {
+	return f.binary(opNotEq, arg)
+}
+
+func (f FuncExpr) Gt(arg any) Predicate {
+	return f.binary(opGT, arg)
+}
+
+func (f FuncExpr) Gte(arg any) Predicate {
+	return f.binary(opGTE, arg)
+}
+
+func (f FuncExpr) Lt(arg any) Predicate {
+	return f.binary(opLT, arg)
+}
+
+func (f FuncExpr) Lte(arg any) Predicate {
+	return f.binary(opLTE, arg)
+}
+
+func (f FuncExpr) Like(pattern string) Predicate {
+	return f.binary(opLike, pattern)
+}
+
+func (f FuncExpr) binary(o op, arg any) Predicate {
+	return Predicate{
+		left:  f,
+		op:    o,
+		right: valueOf(arg),
+	}
+}
+
+// validFuncName 函数名只能是 [A-Za-z_][A-Za-z0-9_]*
+func validFuncName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (f FuncExpr) expr()       {}
+func (f FuncExpr) selectable() {}
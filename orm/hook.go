@@ -0,0 +1,33 @@
+package orm
+
+import "context"
+
+// BeforeInsertHook 在 INSERT 语句构造之前调用，可以用来填充创建时间、校验数据等。
+// 返回 error 会中断 INSERT
+type BeforeInsertHook interface {
+	BeforeInsert(ctx context.Context, qc *QueryContext) error
+}
+
+// AfterInsertHook 在 INSERT 语句执行成功之后调用
+type AfterInsertHook interface {
+	AfterInsert(ctx context.Context, qc *QueryContext) error
+}
+
+// BeforeUpdateHook 在 UPDATE 语句构造之前调用，作用于 Updater.Update 传入的数据。
+// 返回 error 会中断 UPDATE
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context, qc *QueryContext) error
+}
+
+// AfterQueryHook 在查询结果映射到结构体之后调用
+type AfterQueryHook interface {
+	AfterQuery(ctx context.Context) error
+}
+
+// afterQuery 如果 val 实现了 AfterQueryHook，那么调用它
+func afterQuery(ctx context.Context, val any) error {
+	if hook, ok := val.(AfterQueryHook); ok {
+		return hook.AfterQuery(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestInserter_Hook(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectExec("INSERT INTO `hook_model`\\(`id`,`name`,`created_at`,`updated_at`\\) VALUES \\(\\?,\\?,\\?,\\?\\),\\(\\?,\\?,\\?,\\?\\);").
+		WithArgs(int64(1), "Tom", int64(100), int64(0), int64(2), "Jerry", int64(100), int64(0)).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+
+	r := &hookRecorder{}
+	ctx := context.WithValue(context.Background(), hookRecorderKey{}, r)
+	vals := []*HookModel{{Id: 1, Name: "Tom"}, {Id: 2, Name: "Jerry"}}
+	res := NewInserter[HookModel](db).Values(vals...).Exec(ctx)
+	require.NoError(t, res.Err())
+	for _, v := range vals {
+		assert.Equal(t, int64(100), v.CreatedAt)
+	}
+	require.Len(t, r.qcs, 2)
+	assert.Equal(t, "INSERT", r.qcs[0].Type)
+	assert.Equal(t, "hook_model", r.qcs[0].Model.TableName)
+	assert.Equal(t, 2, r.inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// 分批插入，AfterInsert 拿到的是每一批自己的语句
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec("INSERT INTO `hook_model`\\(`id`,`name`,`created_at`,`updated_at`\\) VALUES \\(\\?,\\?,\\?,\\?\\);").
+			WillReturnResult(sqlmock.NewResult(int64(i+3), 1))
+	}
+	r.inserted = 0
+	res = NewInserter[HookModel](db).Values(&HookModel{Id: 3, Name: "Tom"}, &HookModel{Id: 4, Name: "Jerry"}).
+		Batch(1).Exec(ctx)
+	require.NoError(t, res.Err())
+	assert.Equal(t, 2, r.inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// BeforeInsert 返回 error，不会执行 INSERT
+	res = NewInserter[HookModel](db).Values(&HookModel{Id: 3}).Exec(ctx)
+	assert.Equal(t, errors.New("name is required"), res.Err())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdater_Hook(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectExec("UPDATE `hook_model` SET `name`=\\?,`updated_at`=\\? WHERE `id` = \\?;").
+		WithArgs("Tom", int64(200), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := &hookRecorder{}
+	ctx := context.WithValue(context.Background(), hookRecorderKey{}, r)
+	res := NewUpdater[HookModel](db).Update(&HookModel{Name: "Tom"}).Where(C("Id").Eq(1)).Exec(ctx)
+	require.NoError(t, res.Err())
+	require.Len(t, r.qcs, 1)
+	assert.Equal(t, "UPDATE", r.qcs[0].Type)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	res = NewUpdater[HookModel](db).Update(&HookModel{}).Where(C("Id").Eq(1)).Exec(ctx)
+	assert.Equal(t, errors.New("name is required"), res.Err())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_Hook(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"id", "name"})
+	rows.AddRow(1, "Tom")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	rows = sqlmock.NewRows([]string{"id", "name"})
+	rows.AddRow(1, "Tom")
+	rows.AddRow(2, "Jerry")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	rows = sqlmock.NewRows([]string{"id", "name"})
+	rows.AddRow(1, "Tom")
+	rows.AddRow(2, "")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	rows = sqlmock.NewRows([]string{"id", "name"})
+	rows.AddRow(1, "")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	r := &hookRecorder{}
+	ctx := context.WithValue(context.Background(), hookRecorderKey{}, r)
+	_, err = NewSelector[HookModel](db).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.queried)
+
+	multi, err := NewSelector[HookModel](db).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Len(t, multi, 2)
+	assert.Equal(t, 3, r.queried)
+
+	_, err = NewSelector[HookModel](db).GetMulti(ctx)
+	assert.Equal(t, errors.New("name is empty"), err)
+
+	it := NewSelector[HookModel](db).Stream(ctx)
+	assert.False(t, it.Next())
+	assert.Equal(t, errors.New("name is empty"), it.Err())
+	assert.NoError(t, it.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type HookModel struct {
+	Id        int64
+	Name      string
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+// hookRecorder 通过 context 传递给钩子，用于记录钩子的调用情况
+type hookRecorder struct {
+	qcs      []*QueryContext
+	inserted int
+	queried  int
+}
+
+type hookRecorderKey struct{}
+
+func recorderFrom(ctx context.Context) *hookRecorder {
+	return ctx.Value(hookRecorderKey{}).(*hookRecorder)
+}
+
+func (h *HookModel) BeforeInsert(ctx context.Context, qc *QueryContext) error {
+	if h.Name == "" {
+		return errors.New("name is required")
+	}
+	h.CreatedAt = 100
+	r := recorderFrom(ctx)
+	r.qcs = append(r.qcs, qc)
+	return nil
+}
+
+func (h *HookModel) AfterInsert(ctx context.Context, qc *QueryContext) error {
+	if qc.Query != nil {
+		recorderFrom(ctx).inserted++
+	}
+	return nil
+}
+
+func (h *HookModel) BeforeUpdate(ctx context.Context, qc *QueryContext) error {
+	if h.Name == "" {
+		return errors.New("name is required")
+	}
+	h.UpdatedAt = 200
+	r := recorderFrom(ctx)
+	r.qcs = append(r.qcs, qc)
+	return nil
+}
+
+func (h *HookModel) AfterQuery(ctx context.Context) error {
+	if h.Name == "" {
+		return errors.New("name is empty")
+	}
+	recorderFrom(ctx).queried++
+	return nil
+}
@@ -2,6 +2,7 @@ package orm
 
 import (
 	"context"
+	"database/sql"
 	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
 	"gitee.com/geektime-geekbang/geektime-go/orm/model"
 )
@@ -9,11 +10,16 @@ import (
 type UpsertBuilder[T any] struct {
 	i *Inserter[T]
 	conflictColumns []string
+	where []Predicate
 }
 
 type Upsert struct {
 	assigns []Assignable
 	conflictColumns []string
+	// where 冲突的行满足条件才更新
+	where []Predicate
+	// doNothing 为 true 代表冲突的时候什么也不做
+	doNothing bool
 }
 
 // ConflictColumns 这是一个中间方法
@@ -22,10 +28,34 @@ func (o *UpsertBuilder[T]) ConflictColumns(cols...string) *UpsertBuilder[T]{
 	return o
 }
 
+// Where 只有冲突的行满足条件的时候才更新，只对 Update 生效。
+// PostgreSQL 和 SQLite 支持，MySQL 不支持
+func (o *UpsertBuilder[T]) Where(ps...Predicate) *UpsertBuilder[T] {
+	o.where = ps
+	return o
+}
+
+// Update 冲突的时候更新，assigns 可以是 Assignment，也可以是 Column。
+// Column 代表用准备插入的值来更新，Assignment 的值可以是任意表达式，
+// 例如 Assign("Count", C("Count").Add(Excluded("Count")))
 func (o *UpsertBuilder[T]) Update(assigns...Assignable) *Inserter[T]{
 	o.i.onDuplicateKey = &Upsert{
 		assigns: assigns,
 		conflictColumns: o.conflictColumns,
+		where: o.where,
+	}
+	return o.i
+}
+
+// DoNothing 冲突的时候什么也不做，
+// PostgreSQL 和 SQLite 是 ON CONFLICT DO NOTHING，MySQL 是 INSERT IGNORE。
+// INSERT IGNORE 不能指定冲突的列，而且会把数据截断之类的错误也降级为警告，
+// 所以 MySQL 不允许和 ConflictColumns 一起用。
+// 配合 Returning 的时候，被跳过的行不会返回，返回的行数会比插入的行数少
+func (o *UpsertBuilder[T]) DoNothing() *Inserter[T] {
+	o.i.onDuplicateKey = &Upsert{
+		conflictColumns: o.conflictColumns,
+		doNothing: true,
 	}
 	return o.i
 }
@@ -37,20 +67,28 @@ type Assignable interface {
 type Inserter[T any] struct {
 	builder
 	values []*T
-	db *DB
+	sess Session
 	columns []string
 
 	// onDuplicateKey []Assignable
 	onDuplicateKey *Upsert
+	returning []string
+
+	// idField 是需要回填的自增主键，nil 代表不需要回填
+	idField *model.Field
+	// returningID 为 true 代表通过 RETURNING 获取自增主键
+	returningID bool
+
+	// batch 为 true 代表分批插入，batchSize 是每一批的行数
+	batch     bool
+	batchSize int
+	batchInTx bool
 }
 
-func NewInserter[T any](db *DB) *Inserter[T] {
+func NewInserter[T any](sess Session) *Inserter[T] {
 	return &Inserter[T]{
-		builder: builder{
-			dialect: db.dialect,
-			quoter: db.dialect.quoter(),
-		},
-		db: db,
+		builder: newBuilder(sess),
+		sess:    sess,
 	}
 }
 
@@ -72,17 +110,42 @@ func (i *Inserter[T]) Values(vals...*T) *Inserter[T] {
 	return i
 }
 
+// Columns 指定插入的列，传入的是字段名
+// 不指定的时候插入除了自增列以外的所有列
 func (i *Inserter[T]) Columns(cols...string) *Inserter[T] {
 	i.columns = cols
 	return i
 }
 
+// Returning 指定 RETURNING 的列，传入的是字段名
+// MySQL 不支持 RETURNING。PostgreSQL 指定了之后不会再回填自增主键
+func (i *Inserter[T]) Returning(cols ...string) *Inserter[T] {
+	i.returning = cols
+	return i
+}
+
+// Batch 分批插入，每一批最多 size 行，size <= 0 的时候根据方言的参数个数限制来计算。
+// 每一批都是一条单独的 INSERT 语句，只影响 Exec，Build 还是一条语句。
+// 所有批次都成功之后才会调用 AfterInsertHook，拿到的是这一行所在的那一批的上下文
+func (i *Inserter[T]) Batch(size int) *Inserter[T] {
+	i.batch = true
+	i.batchSize = size
+	return i
+}
+
+// InTx 分批插入的时候所有批次在同一个事务里面执行，任何一批失败都会回滚。
+// 本身就是在事务里面的话，直接用这个事务
+func (i *Inserter[T]) InTx() *Inserter[T] {
+	i.batchInTx = true
+	return i
+}
+
 func (i *Inserter[T]) Build() (*Query, error) {
 	if len(i.values) == 0 {
 		return nil, errs.ErrInsertZeroRow
 	}
-	i.sb.WriteString("INSERT INTO ")
-	m, err := i.db.r.Get(i.values[0])
+	i.dialect.buildInsertInto(&i.builder, i.onDuplicateKey)
+	m, err := i.r.Get(i.values[0])
 	i.model = m
 	if err != nil {
 		return nil, err
@@ -93,18 +156,9 @@ func (i *Inserter[T]) Build() (*Query, error) {
 	// 我们要构造 `test_model`(col1, col2...)
 	i.sb.WriteByte('(')
 
-	fields := m.Fields
-	// 用户指定了
-	if len(i.columns) > 0 {
-		fields = make([]*model.Field, 0, len(i.columns))
-		for _, fd := range i.columns {
-			fdMeta, ok := m.FieldMap[fd]
-			// 传入了乱七八糟的列
-			if !ok {
-				return nil, errs.NewErrUnknownField(fd)
-			}
-			fields = append(fields, fdMeta)
-		}
+	fields, err := i.fields(m)
+	if err != nil {
+		return nil, err
 	}
 
 	// 不能遍历这个 FieldMap，ColMap，因为在 Go 里面 map 的遍历，每一次的顺序都不一样
@@ -122,12 +176,13 @@ func (i *Inserter[T]) Build() (*Query, error) {
 	i.sb.WriteString(" VALUES ")
 	// 预估的参数数量是：我有多少行乘以我有多少个字段
 	i.args = make([]any, 0, len(i.values) * len(fields))
+	i.argCols = make([]string, 0, cap(i.args))
 	for j, v := range i.values {
 		if j >0 {
 			i.sb.WriteByte(',')
 		}
 		i.sb.WriteByte('(')
-		val := i.db.creator(i.model, v)
+		val := i.creator(i.model, v)
 		for idx, field := range fields {
 			if idx > 0 {
 				i.sb.WriteByte(',')
@@ -138,7 +193,7 @@ func (i *Inserter[T]) Build() (*Query, error) {
 			if err != nil {
 				return nil, err
 			}
-			i.addArg(arg)
+			i.addColArg(field.ColName, arg)
 		}
 		i.sb.WriteByte(')')
 	}
@@ -149,24 +204,359 @@ func (i *Inserter[T]) Build() (*Query, error) {
 			return nil, err
 		}
 	}
+	i.idField = i.autoIncrementPK(len(i.columns) > 0)
+	i.returningID = false
+	if len(i.returning) > 0 {
+		if err = i.dialect.buildReturning(&i.builder, i.returning); err != nil {
+			return nil, err
+		}
+		// 用户自己指定了 RETURNING，不支持 LastInsertId 的方言就没办法回填了
+		if _, ok := i.dialect.insertIDs(0, 0); !ok {
+			i.idField = nil
+		}
+	} else if i.idField != nil {
+		// 不支持 LastInsertId 的方言，例如 PostgreSQL，通过 RETURNING 拿到自增主键
+		if _, ok := i.dialect.insertIDs(0, 0); !ok {
+			if err = i.dialect.buildReturning(&i.builder, []string{i.idField.GoName}); err != nil {
+				return nil, err
+			}
+			i.returningID = true
+		}
+	}
 	i.sb.WriteByte(';')
-	return &Query{SQL: i.sb.String(), Args: i.args}, nil
+	return i.buildQuery(), nil
+}
+
+// fields 返回要插入的列
+func (i *Inserter[T]) fields(m *model.Model) ([]*model.Field, error) {
+	// 用户指定了
+	if len(i.columns) > 0 {
+		fields := make([]*model.Field, 0, len(i.columns))
+		for _, fd := range i.columns {
+			fdMeta, ok := m.FieldMap[fd]
+			// 传入了乱七八糟的列
+			if !ok {
+				return nil, errs.NewErrUnknownField(fd)
+			}
+			fields = append(fields, fdMeta)
+		}
+		return fields, nil
+	}
+	// 自增列交给数据库生成，除非用户通过 Columns 显式指定
+	fields := make([]*model.Field, 0, len(m.Fields))
+	for _, fd := range m.Fields {
+		if !fd.AutoIncrement {
+			fields = append(fields, fd)
+		}
+	}
+	return fields, nil
+}
+
+// autoIncrementPK 找到需要回填的自增主键
+// 用户显式指定了列，说明主键的值是用户自己给的；
+// UPSERT 的时候有些行是更新，没办法知道每一行对应的 ID，所以都不回填
+func (i *Inserter[T]) autoIncrementPK(explicitColumns bool) *model.Field {
+	if explicitColumns || i.onDuplicateKey != nil {
+		return nil
+	}
+	for _, fd := range i.model.Fields {
+		if fd.PrimaryKey && fd.AutoIncrement {
+			return fd
+		}
+	}
+	return nil
 }
 
 func (i *Inserter[T]) Exec(ctx context.Context) Result {
+	m, err := i.r.Get(new(T))
+	if err != nil {
+		return Result{
+			err: err,
+		}
+	}
+	qc := &QueryContext{
+		Type:    "INSERT",
+		Builder: i,
+		Model:   m,
+	}
+	// 钩子要在 Build 之前调用，这样钩子里面修改的数据才会被插入
+	for _, v := range i.values {
+		if hook, ok := any(v).(BeforeInsertHook); ok {
+			if err = hook.BeforeInsert(ctx, qc); err != nil {
+				return Result{
+					err: err,
+				}
+			}
+		}
+	}
+
+	// 分批插入不会调用 Build，所以要在这里检查
+	if len(i.values) == 0 {
+		return Result{
+			err: errs.ErrInsertZeroRow,
+		}
+	}
+	if !i.batch {
+		res := i.execOnce(ctx, qc)
+		if res.err == nil {
+			res.err = i.afterInsert(ctx, qc)
+		}
+		return res
+	}
+	res, chunks := i.execBatch(ctx, m)
+	if res.err != nil {
+		return res
+	}
+	// 分批插入的时候，钩子拿到的是这一行所在的那一批的语句
+	for _, chunk := range chunks {
+		if err = chunk.Builder.(*Inserter[T]).afterInsert(ctx, chunk); err != nil {
+			res.err = err
+			break
+		}
+	}
+	return res
+}
+
+// afterInsert 所有的语句都执行成功之后才调用 AfterInsertHook
+func (i *Inserter[T]) afterInsert(ctx context.Context, qc *QueryContext) error {
+	for _, v := range i.values {
+		if hook, ok := any(v).(AfterInsertHook); ok {
+			if err := hook.AfterInsert(ctx, qc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// execOnce 用一条 INSERT 语句插入所有的数据
+func (i *Inserter[T]) execOnce(ctx context.Context, qc *QueryContext) Result {
 	q, err := i.Build()
 	if err != nil {
 		return Result{
 			err: err,
 		}
 	}
-	res, err := i.db.db.Exec(q.SQL, q.Args...)
+	qc.Query = q
+	qc.ArgColumns = i.argCols
+	var res Result
+	if i.returningID {
+		res = i.execReturningID(ctx, qc)
+	} else {
+		res = exec(ctx, i.sess, qc)
+	}
+	if res.err != nil {
+		return res
+	}
+	if i.idField != nil {
+		if err = i.backFillIDs(res.res); err != nil {
+			res.err = err
+		}
+	}
+	return res
+}
+
+// execBatch 分批插入，需要的话在同一个事务里面执行，
+// 返回的 QueryContext 是每一批的上下文
+func (i *Inserter[T]) execBatch(ctx context.Context, m *model.Model) (Result, []*QueryContext) {
+	fields, err := i.fields(m)
+	if err != nil {
+		return Result{
+			err: err,
+		}, nil
+	}
+	upsertArgs, err := i.upsertArgs(m)
+	if err != nil {
+		return Result{
+			err: err,
+		}, nil
+	}
+	size := i.batchRows(len(fields), upsertArgs)
+	db, ok := i.sess.(*DB)
+	if !i.batchInTx || !ok {
+		return i.execChunks(ctx, i.sess, m, size)
+	}
+	var (
+		res    Result
+		chunks []*QueryContext
+	)
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		res, chunks = i.execChunks(ctx, tx, m, size)
+		return res.err
+	}, nil)
+	if err != nil {
+		// 事务回滚了，前面插入成功的也不算数了
+		return Result{
+			err: err,
+		}, nil
+	}
+	return res, chunks
+}
+
+// upsertArgs 单独构造一次 UPSERT 部分，看看里面有多少个参数
+func (i *Inserter[T]) upsertArgs(m *model.Model) (int, error) {
+	if i.onDuplicateKey == nil {
+		return 0, nil
+	}
+	b := newBuilder(i.sess)
+	b.model = m
+	if err := i.dialect.buildUpsert(&b, i.onDuplicateKey); err != nil {
+		return 0, err
+	}
+	return len(b.args), nil
+}
+
+// batchRows 计算每一批最多插入多少行，参数个数不能超过方言的限制，
+// 每一批的 UPSERT 部分都有 upsertArgs 个参数
+func (i *Inserter[T]) batchRows(cols int, upsertArgs int) int {
+	maxArgs := i.dialect.maxArgs() - upsertArgs
+	rows := 1
+	if cols > 0 && maxArgs/cols > 1 {
+		rows = maxArgs / cols
+	}
+	if i.batchSize > 0 && i.batchSize < rows {
+		rows = i.batchSize
+	}
+	return rows
+}
+
+// execChunks 每一批都用一个新的 Inserter 来构造和执行，
+// 所以 middleware 看到的是一批一批的 INSERT 语句
+func (i *Inserter[T]) execChunks(ctx context.Context, sess Session, m *model.Model, size int) (Result, []*QueryContext) {
+	results := make(batchResult, 0, (len(i.values)+size-1)/size)
+	chunks := make([]*QueryContext, 0, cap(results))
+	for start := 0; start < len(i.values); start += size {
+		end := start + size
+		if end > len(i.values) {
+			end = len(i.values)
+		}
+		chunk := NewInserter[T](sess)
+		chunk.values = i.values[start:end]
+		chunk.columns = i.columns
+		chunk.onDuplicateKey = i.onDuplicateKey
+		chunk.returning = i.returning
+		qc := &QueryContext{
+			Type:    "INSERT",
+			Builder: chunk,
+			Model:   m,
+		}
+		res := chunk.execOnce(ctx, qc)
+		if res.err != nil {
+			return Result{
+				err: res.err,
+				res: results,
+			}, nil
+		}
+		results = append(results, res.res)
+		chunks = append(chunks, qc)
+	}
+	return Result{
+		res: results,
+	}, chunks
+}
+
+// batchResult 是分批插入的结果，RowsAffected 是所有批次的总和，
+// LastInsertId 是最后一批的
+type batchResult []sql.Result
+
+func (r batchResult) LastInsertId() (int64, error) {
+	for idx := len(r) - 1; idx >= 0; idx-- {
+		if r[idx] != nil {
+			return r[idx].LastInsertId()
+		}
+	}
+	return 0, nil
+}
+
+func (r batchResult) RowsAffected() (int64, error) {
+	var sum int64
+	for _, res := range r {
+		// 被 middleware 拦截了之类的
+		if res == nil {
+			continue
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		sum += n
+	}
+	return sum, nil
+}
+
+// execReturningID 通过 RETURNING 获取自增主键，
+// 结果和 exec 一样是 sql.Result，middleware 不需要区别对待
+func (i *Inserter[T]) execReturningID(ctx context.Context, qc *QueryContext) Result {
+	res := i.handle(ctx, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		rows, err := i.sess.queryContext(ctx, qc.Query.SQL, qc.Query.Args...)
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		ids := make([]int64, 0, len(i.values))
+		for rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				return &QueryResult{Err: err}
+			}
+			ids = append(ids, id)
+		}
+		if err = rows.Err(); err != nil {
+			return &QueryResult{Err: err}
+		}
+		return &QueryResult{Result: returningResult{ids: ids}}
+	})
+	sqlRes, _ := res.Result.(sql.Result)
 	return Result{
-		err: err,
-		res: res,
+		err: res.Err,
+		res: sqlRes,
 	}
 }
 
+// backFillIDs 把自增主键设置回 i.values
+func (i *Inserter[T]) backFillIDs(res sql.Result) error {
+	var ids []int64
+	if r, ok := res.(returningResult); ok {
+		ids = r.ids
+	} else {
+		// 被 middleware 拦截了之类的，就不回填了
+		if res == nil {
+			return nil
+		}
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		ids, _ = i.dialect.insertIDs(lastID, len(i.values))
+	}
+	if len(ids) != len(i.values) {
+		return errs.NewErrInsertIDCount(len(i.values), len(ids))
+	}
+	for idx, v := range i.values {
+		if err := i.creator(i.model, v).SetField(i.idField.GoName, ids[idx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returningResult 是通过 RETURNING 获取自增主键的时候的 sql.Result
+type returningResult struct {
+	ids []int64
+}
+
+func (r returningResult) LastInsertId() (int64, error) {
+	if len(r.ids) == 0 {
+		return 0, nil
+	}
+	return r.ids[len(r.ids)-1], nil
+}
+
+func (r returningResult) RowsAffected() (int64, error) {
+	return int64(len(r.ids)), nil
+}
 
 // type MySQLInserter struct {
 //
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -56,6 +57,41 @@ func TestInserter_SQLite_upsert(t *testing.T) {
 					int64(13), "DaMing", int8(19), &sql.NullString{String: "Deng", Valid: true}},
 			},
 		},
+		{
+			name: "upsert-do nothing",
+			i: NewInserter[TestModel](db).Columns("Id").Values(&TestModel{Id: 12}).
+				OnDuplicateKey().ConflictColumns("Id").DoNothing(),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`) VALUES (?) ON CONFLICT(`id`) DO NOTHING;",
+				Args: []any{int64(12)},
+			},
+		},
+		{
+			name: "upsert-do nothing without conflict columns",
+			i: NewInserter[TestModel](db).Columns("Id").Values(&TestModel{Id: 12}).
+				OnDuplicateKey().DoNothing(),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`) VALUES (?) ON CONFLICT DO NOTHING;",
+				Args: []any{int64(12)},
+			},
+		},
+		{
+			name: "upsert-update where",
+			i: NewInserter[TestModel](db).Columns("Id", "Age").Values(&TestModel{Id: 12, Age: 18}).
+				OnDuplicateKey().ConflictColumns("Id").Where(C("Age").Lt(Excluded("Age"))).
+				Update(Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`age`) VALUES (?,?) ON CONFLICT(`id`) " +
+					"DO UPDATE SET `age`=`age` + excluded.`age` WHERE `age` < excluded.`age`;",
+				Args: []any{int64(12), int8(18)},
+			},
+		},
+		{
+			name: "upsert-update without conflict columns",
+			i: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnDuplicateKey().Update(C("Age")),
+			wantErr: errs.ErrUpsertWithoutConflictColumns,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -119,6 +155,25 @@ func TestInserter_Build(t *testing.T) {
 			},
 		},
 
+		{
+			// 自增列默认不插入
+			name: "auto increment",
+			i: NewInserter[AutoIncModel](db).Values(&AutoIncModel{Id: 12, Name: "Tom"}),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `auto_inc_model`(`name`) VALUES (?);",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			// 显式指定了就插入
+			name: "auto increment with columns",
+			i: NewInserter[AutoIncModel](db).Columns("Id", "Name").
+				Values(&AutoIncModel{Id: 12, Name: "Tom"}),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `auto_inc_model`(`id`,`name`) VALUES (?,?);",
+				Args: []any{int64(12), "Tom"},
+			},
+		},
 		{
 			// 插入多行、部分列
 			name: "partial columns",
@@ -173,6 +228,39 @@ func TestInserter_Build(t *testing.T) {
 					int64(13), "DaMing", int8(19), &sql.NullString{String: "Deng", Valid: true}},
 			},
 		},
+		{
+			name: "upsert-do nothing",
+			i: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				Values(&TestModel{Id: 12, FirstName: "Tom"}).OnDuplicateKey().DoNothing(),
+			wantQuery: &Query{
+				SQL: "INSERT IGNORE INTO `test_model`(`id`,`first_name`) VALUES (?,?);",
+				Args: []any{int64(12), "Tom"},
+			},
+		},
+		{
+			name: "upsert-update expression",
+			i: NewInserter[TestModel](db).Columns("Id", "Age").
+				Values(&TestModel{Id: 12, Age: 18}).OnDuplicateKey().
+				Update(Assign("Age", C("Age").Add(Excluded("Age")).Add(1))),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`age`) VALUES (?,?) " +
+					"ON DUPLICATE KEY UPDATE `age`=(`age` + VALUES(`age`)) + ?;",
+				Args: []any{int64(12), int8(18), 1},
+			},
+		},
+		{
+			name: "upsert-where",
+			i: NewInserter[TestModel](db).Values(&TestModel{}).OnDuplicateKey().
+				Where(C("Age").Lt(18)).Update(C("Age")),
+			wantErr: errs.NewErrUnsupportedDialectFeature("MySQL", "UPSERT WHERE"),
+		},
+		{
+			// INSERT IGNORE 没办法指定冲突的列
+			name: "upsert-do nothing with conflict columns",
+			i: NewInserter[TestModel](db).Values(&TestModel{}).OnDuplicateKey().
+				ConflictColumns("Id").DoNothing(),
+			wantErr: errs.NewErrUnsupportedDialectFeature("MySQL", "DO NOTHING 指定冲突的列"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -238,3 +326,343 @@ func TestInserter_Exec(t *testing.T) {
 		})
 	}
 }
+
+type AutoIncModel struct {
+	Id   int64 `orm:"pk,auto_increment"`
+	Name string
+}
+
+func TestInserter_BackFillID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		mock    func(mock sqlmock.Sqlmock)
+		vals    []*AutoIncModel
+		// returning 是用户自己指定的 RETURNING
+		returning []string
+
+		wantErr error
+		wantIds []int64
+	}{
+		{
+			// MySQL 返回的是第一行的 ID
+			name:    "mysql",
+			dialect: DialectMySQL,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_inc_model`\\(`name`\\) VALUES \\(\\?\\),\\(\\?\\);").
+					WillReturnResult(sqlmock.NewResult(10, 2))
+			},
+			vals:    []*AutoIncModel{{Name: "Tom"}, {Name: "Jerry"}},
+			wantIds: []int64{10, 11},
+		},
+		{
+			// SQLite 返回的是最后一行的 ID
+			name:    "sqlite",
+			dialect: DialectSQLite,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnResult(sqlmock.NewResult(11, 2))
+			},
+			vals:    []*AutoIncModel{{Name: "Tom"}, {Name: "Jerry"}},
+			wantIds: []int64{10, 11},
+		},
+		{
+			name:    "postgresql",
+			dialect: DialectPostgreSQL,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("INSERT INTO \"auto_inc_model\"\\(\"name\"\\) VALUES \\(\\$1\\),\\(\\$2\\) RETURNING \"id\";").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+			},
+			vals:    []*AutoIncModel{{Name: "Tom"}, {Name: "Jerry"}},
+			wantIds: []int64{10, 11},
+		},
+		{
+			name:    "postgresql id count",
+			dialect: DialectPostgreSQL,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("INSERT INTO .*").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+			},
+			vals:    []*AutoIncModel{{Name: "Tom"}, {Name: "Jerry"}},
+			wantErr: errs.NewErrInsertIDCount(2, 1),
+		},
+		{
+			// 用户自己指定了 RETURNING，PostgreSQL 不支持 LastInsertId，所以不回填
+			name:    "postgresql user returning",
+			dialect: DialectPostgreSQL,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO \"auto_inc_model\"\\(\"name\"\\) VALUES \\(\\$1\\) RETURNING \"name\";").
+					WillReturnResult(sqlmock.NewErrorResult(errors.New("no LastInsertId available")))
+			},
+			vals:      []*AutoIncModel{{Name: "Tom"}},
+			returning: []string{"Name"},
+			wantIds:   []int64{0},
+		},
+		{
+			name:    "last insert id error",
+			dialect: DialectMySQL,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnResult(sqlmock.NewErrorResult(errors.New("no id")))
+			},
+			vals:    []*AutoIncModel{{Name: "Tom"}},
+			wantErr: errors.New("no id"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := OpenDB(mockDB, DBWithDialect(tc.dialect))
+			require.NoError(t, err)
+			tc.mock(mock)
+
+			err = NewInserter[AutoIncModel](db).Values(tc.vals...).
+				Returning(tc.returning...).Exec(context.Background()).Err()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			ids := make([]int64, 0, len(tc.vals))
+			for _, v := range tc.vals {
+				ids = append(ids, v.Id)
+			}
+			assert.Equal(t, tc.wantIds, ids)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestInserter_BackFillID_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:back_fill_id.db?cache=shared&mode=memory",
+		DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	_, err = db.db.Exec("CREATE TABLE `auto_inc_model`(`id` INTEGER PRIMARY KEY AUTOINCREMENT, `name` TEXT)")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tom := &AutoIncModel{Name: "Tom"}
+	require.NoError(t, NewInserter[AutoIncModel](db).Values(tom).Exec(ctx).Err())
+	assert.Equal(t, int64(1), tom.Id)
+
+	vals := []*AutoIncModel{{Name: "Jerry"}, {Name: "DaMing"}}
+	require.NoError(t, NewInserter[AutoIncModel](db).Values(vals...).Exec(ctx).Err())
+	assert.Equal(t, int64(2), vals[0].Id)
+	assert.Equal(t, int64(3), vals[1].Id)
+
+	res, err := NewSelector[AutoIncModel](db).Where(C("Id").Eq(3)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, vals[1], res)
+}
+
+func TestInserter_Batch(t *testing.T) {
+	vals := func(n int) []*AutoIncModel {
+		res := make([]*AutoIncModel, 0, n)
+		for i := 0; i < n; i++ {
+			res = append(res, &AutoIncModel{Name: fmt.Sprintf("name_%d", i)})
+		}
+		return res
+	}
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+		i    func(db *DB) *Inserter[AutoIncModel]
+
+		wantErr      error
+		wantAffected int64
+	}{
+		{
+			name: "batch size",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_inc_model`\\(`name`\\) VALUES \\(\\?\\),\\(\\?\\);").
+					WithArgs("name_0", "name_1").
+					WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectExec("INSERT INTO `auto_inc_model`\\(`name`\\) VALUES \\(\\?\\),\\(\\?\\);").
+					WithArgs("name_2", "name_3").
+					WillReturnResult(sqlmock.NewResult(3, 2))
+				mock.ExpectExec("INSERT INTO `auto_inc_model`\\(`name`\\) VALUES \\(\\?\\);").
+					WithArgs("name_4").
+					WillReturnResult(sqlmock.NewResult(5, 1))
+			},
+			i: func(db *DB) *Inserter[AutoIncModel] {
+				return NewInserter[AutoIncModel](db).Values(vals(5)...).Batch(2)
+			},
+			wantAffected: 5,
+		},
+		{
+			name: "no values",
+			mock: func(mock sqlmock.Sqlmock) {},
+			i: func(db *DB) *Inserter[AutoIncModel] {
+				return NewInserter[AutoIncModel](db).Values().Batch(2).InTx()
+			},
+			wantErr: errs.ErrInsertZeroRow,
+		},
+		{
+			// 没有指定的时候按照 MySQL 的 65535 个参数来切
+			name: "dialect limit",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnResult(sqlmock.NewResult(1, 65535))
+				mock.ExpectExec("INSERT INTO `auto_inc_model`\\(`name`\\) VALUES \\(\\?\\),\\(\\?\\);").
+					WillReturnResult(sqlmock.NewResult(65536, 2))
+			},
+			i: func(db *DB) *Inserter[AutoIncModel] {
+				return NewInserter[AutoIncModel](db).Values(vals(65537)...).Batch(0)
+			},
+			wantAffected: 65537,
+		},
+		{
+			// UPSERT 部分的参数也要算进去
+			name: "upsert args",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnResult(sqlmock.NewResult(1, 65533))
+				mock.ExpectExec("INSERT INTO `auto_inc_model`\\(`name`\\) VALUES \\(\\?\\) " +
+					"ON DUPLICATE KEY UPDATE `name`=\\(CONCAT\\(\\?, \\?\\)\\);").
+					WithArgs("name_65533", "a", "b").
+					WillReturnResult(sqlmock.NewResult(65534, 1))
+			},
+			i: func(db *DB) *Inserter[AutoIncModel] {
+				return NewInserter[AutoIncModel](db).Values(vals(65534)...).
+					OnDuplicateKey().Update(Assign("Name", Raw("CONCAT(?, ?)", "a", "b"))).Batch(0)
+			},
+			wantAffected: 65534,
+		},
+		{
+			// 没有事务的时候，前面成功的批次不会回滚
+			name: "exec error",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnError(errors.New("db error"))
+			},
+			i: func(db *DB) *Inserter[AutoIncModel] {
+				return NewInserter[AutoIncModel](db).Values(vals(3)...).Batch(2)
+			},
+			wantErr: errors.New("db error"),
+		},
+		{
+			name: "tx",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectCommit()
+			},
+			i: func(db *DB) *Inserter[AutoIncModel] {
+				return NewInserter[AutoIncModel](db).Values(vals(3)...).Batch(2).InTx()
+			},
+			wantAffected: 3,
+		},
+		{
+			name: "tx rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectExec("INSERT INTO .*").
+					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
+			},
+			i: func(db *DB) *Inserter[AutoIncModel] {
+				return NewInserter[AutoIncModel](db).Values(vals(3)...).Batch(2).InTx()
+			},
+			wantErr: errors.New("db error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := OpenDB(mockDB, DBWithDialect(DialectMySQL))
+			require.NoError(t, err)
+			tc.mock(mock)
+
+			affected, err := tc.i(db).Exec(context.Background()).RowsAffected()
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantAffected, affected)
+		})
+	}
+}
+
+func TestInserter_Batch_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:batch_insert.db?cache=shared&mode=memory",
+		DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	_, err = db.db.Exec("CREATE TABLE `auto_inc_model`(`id` INTEGER PRIMARY KEY AUTOINCREMENT, `name` TEXT)")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 超过了 SQLite 999 个参数的限制
+	vals := make([]*AutoIncModel, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		vals = append(vals, &AutoIncModel{Name: fmt.Sprintf("name_%d", i)})
+	}
+	err = NewInserter[AutoIncModel](db).Values(vals...).Exec(ctx).Err()
+	assert.Error(t, err)
+
+	res := NewInserter[AutoIncModel](db).Values(vals...).Batch(0).InTx().Exec(ctx)
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2000), affected)
+	// 每一批都会回填主键
+	assert.Equal(t, int64(1), vals[0].Id)
+	assert.Equal(t, int64(2000), vals[1999].Id)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2000), id)
+
+	got, err := NewSelector[AutoIncModel](db).Where(C("Id").Eq(1000)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "name_999", got.Name)
+}
+
+func TestInserter_MySQL8_upsert(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(DialectMySQL8))
+	q, err := NewInserter[TestModel](db).Columns("Id", "Age").
+		Values(&TestModel{Id: 12, Age: 18}).OnDuplicateKey().
+		Update(C("Age"), Assign("FirstName", Raw("'Tom'")), Assign("Id", C("Id").Add(Excluded("Id")))).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL: "INSERT INTO `test_model`(`id`,`age`) VALUES (?,?) AS `new` ON DUPLICATE KEY UPDATE " +
+			"`age`=`new`.`age`,`first_name`=('Tom'),`id`=`id` + `new`.`id`;",
+		Args: []any{int64(12), int8(18)},
+	}, q)
+}
+
+func TestInserter_Upsert_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:upsert.db?cache=shared&mode=memory",
+		DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	_, err = db.db.Exec("CREATE TABLE `test_model`(`id` INTEGER PRIMARY KEY, `first_name` TEXT, `age` INTEGER, `last_name` TEXT)")
+	require.NoError(t, err)
+	ctx := context.Background()
+	get := func() *TestModel {
+		res, err := NewSelector[TestModel](db).Where(C("Id").Eq(1)).Get(ctx)
+		require.NoError(t, err)
+		return res
+	}
+	insert := func() *UpsertBuilder[TestModel] {
+		return NewInserter[TestModel](db).Columns("Id", "FirstName", "Age").
+			Values(&TestModel{Id: 1, FirstName: "Jerry", Age: 10}).OnDuplicateKey().ConflictColumns("Id")
+	}
+	require.NoError(t, NewInserter[TestModel](db).Columns("Id", "FirstName", "Age").
+		Values(&TestModel{Id: 1, FirstName: "Tom", Age: 18}).Exec(ctx).Err())
+
+	require.NoError(t, insert().DoNothing().Exec(ctx).Err())
+	assert.Equal(t, "Tom", get().FirstName)
+
+	// 不满足条件，不会更新
+	require.NoError(t, insert().Where(C("Age").Lt(Excluded("Age"))).Update(C("FirstName")).Exec(ctx).Err())
+	assert.Equal(t, "Tom", get().FirstName)
+
+	require.NoError(t, insert().Update(Assign("Age", C("Age").Add(Excluded("Age")))).Exec(ctx).Err())
+	assert.Equal(t, int8(28), get().Age)
+}
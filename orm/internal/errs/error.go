@@ -13,6 +13,16 @@ var (
 	ErrNoRows = errors.New("orm: 没有数据")
 	// ErrInsertZeroRow 代表插入 0 行
 	ErrInsertZeroRow = errors.New("orm: 插入 0 行")
+	// ErrNoUpdatedColumns 代表 UPDATE 语句没有任何需要更新的列
+	ErrNoUpdatedColumns = errors.New("orm: 未指定更新的列")
+	// ErrUpsertWithoutConflictColumns 代表 ON CONFLICT ... DO UPDATE 没有指定冲突的列
+	ErrUpsertWithoutConflictColumns = errors.New("orm: UPSERT 未指定冲突列，请调用 ConflictColumns")
+	// ErrDeleteWithoutWhere 代表 DELETE 语句没有 WHERE 条件，会删除整张表的数据
+	ErrDeleteWithoutWhere = errors.New("orm: DELETE 语句没有 WHERE 条件，如果确实要删除全表数据，请调用 AllowNoWhere")
+	// ErrCaseWithoutWhen 代表 CASE 表达式没有任何 WHEN 分支
+	ErrCaseWithoutWhen = errors.New("orm: CASE 表达式至少需要一个 WHEN")
+	// ErrExcludedOutsideUpsert 代表在 UPSERT 的赋值和条件以外的地方用了 Excluded
+	ErrExcludedOutsideUpsert = errors.New("orm: Excluded 只能在 UPSERT 的 Update 和 Where 里面使用")
 )
 
 // func NewErrUnsupportedExpressionV1(expr any) error {
@@ -29,15 +39,78 @@ func NewErrUnknownField(name string) error {
 	return fmt.Errorf("orm: 未知字段 %s", name)
 }
 
+// NewErrInsertIDCount 获得的自增主键数量和插入的行数对不上
+func NewErrInsertIDCount(rows int, ids int) error {
+	return fmt.Errorf("orm: 插入了 %d 行，但是只拿到了 %d 个自增主键", rows, ids)
+}
+
+// NewErrInvalidFieldValue 值的类型没办法转换为字段的类型
+func NewErrInvalidFieldValue(typ string, val any) error {
+	return fmt.Errorf("orm: 值 %v 不能赋值给类型为 %s 的字段", val, typ)
+}
+
 func NewErrUnknownColumn(name string) error {
 	return fmt.Errorf("orm: 未知列 %s", name)
 }
 
+// NewErrAmbiguousField 组合进来的多个结构体在同一层有同名字段
+func NewErrAmbiguousField(name string) error {
+	return fmt.Errorf("orm: 字段 %s 有歧义，多个组合的结构体中都有这个字段", name)
+}
+
+// NewErrEmbeddedPointer 不支持组合结构体指针
+func NewErrEmbeddedPointer(name string) error {
+	return fmt.Errorf("orm: 不支持组合结构体指针 %s，请使用结构体", name)
+}
+
 func NewErrInvalidTagContent(pair string) error {
 	return fmt.Errorf("orm: 非法标签值 %s", pair)
 }
 
 
+func NewErrUnsupportedSelectable(exp any) error {
+	return fmt.Errorf("orm: 不支持的目标列 %v", exp)
+}
+
+func NewErrUnsupportedTable(table any) error {
+	return fmt.Errorf("orm: 不支持的表类型 %v", table)
+}
+
+// NewErrUnsupportedColumnType 没办法根据 Go 类型推断出列的类型，需要通过 type 标签指定
+func NewErrUnsupportedColumnType(field string, typ string) error {
+	return fmt.Errorf("orm: 无法推断字段 %s 的列类型 %s，请使用 type 标签指定", field, typ)
+}
+
+func NewErrUnsupportedDialectFeature(dialect string, feature string) error {
+	return fmt.Errorf("orm: %s 不支持 %s", dialect, feature)
+}
+
+// NewErrInvalidFuncName 函数名不是合法的标识符，为了避免 SQL 注入直接拒绝
+func NewErrInvalidFuncName(name string) error {
+	return fmt.Errorf("orm: 非法的函数名 %s", name)
+}
+
 func NewErrUnsupportedAssignable(expr any) error {
 	return fmt.Errorf("orm: 不支持的赋值表达式类型 %v", expr)
-}
\ No newline at end of file
+}
+
+// NewErrNoZeroDefault 往已有的表里面加 NOT NULL 的列需要默认值，但是这个类型没有合适的常量
+func NewErrNoZeroDefault(field string) error {
+	return fmt.Errorf("orm: 字段 %s 没有合适的常量默认值，往已有的表里面加列请通过 default 标签指定", field)
+}
+
+// NewErrColumnChanged 已有的列和模型的定义不一致，Diff 不会修改已有的列
+func NewErrColumnChanged(table string, col string, what string, from string, to string) error {
+	return fmt.Errorf("orm: 表 %s 的列 %s 的%s从 %s 变成了 %s，不支持自动修改，请通过 Migration 手动修改",
+		table, col, what, from, to)
+}
+
+// NewErrDuplicateMigration 多个 Migration 用了同一个版本号
+func NewErrDuplicateMigration(version int64) error {
+	return fmt.Errorf("orm: 重复的 migration 版本 %d", version)
+}
+
+// NewErrFailToRollbackTx 代表业务出错之后，回滚事务也失败了
+func NewErrFailToRollbackTx(bizErr error, rbErr error) error {
+	return fmt.Errorf("orm: 回滚事务失败, 业务错误 %w, 回滚错误 %s", bizErr, rbErr.Error())
+}
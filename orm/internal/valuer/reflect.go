@@ -12,11 +12,16 @@ type reflectValue struct {
 	// 对应于 T 的指针
 	// val any
 	val reflect.Value
+	// ignoreUnknown 为 true 的时候，丢弃模型里面没有的列
+	ignoreUnknown bool
 }
 
 var _ Creator = NewReflectValue
 
 func NewReflectValue(model *model.Model, val any) Value {
+	if g, ok := val.(Generated); ok {
+		return g.OrmValue(model, false)
+	}
 	return reflectValue{
 		model: model,
 		val: reflect.ValueOf(val).Elem(),
@@ -36,6 +41,14 @@ func (r reflectValue) Field(name string) (any, error) {
 	return val.Interface(), nil
 }
 
+func (r reflectValue) SetField(name string, val any) error {
+	fd, ok := r.model.FieldMap[name]
+	if !ok {
+		return errs.NewErrUnknownField(name)
+	}
+	return setValue(r.val.FieldByName(fd.GoName), val)
+}
+
 func (r reflectValue) SetColumns(rows *sql.Rows) error {
 	// 在这里，继续处理结果集
 
@@ -57,7 +70,13 @@ func (r reflectValue) SetColumns(rows *sql.Rows) error {
 		// c 是列名
 		fd, ok := r.model.ColumnMap[c]
 		if !ok {
-			return errs.NewErrUnknownColumn(c)
+			if !r.ignoreUnknown {
+				return errs.NewErrUnknownColumn(c)
+			}
+			// 随便找个地方接收这一列，后面不会用到
+			vals = append(vals, new(any))
+			valElems = append(valElems, reflect.Value{})
+			continue
 		}
 		// 反射创建一个实例
 		// 这里创建的实例是原本类型的指针类型
@@ -84,7 +103,7 @@ func (r reflectValue) SetColumns(rows *sql.Rows) error {
 		// c 是列名
 		fd, ok := r.model.ColumnMap[c]
 		if !ok {
-			return errs.NewErrUnknownColumn(c)
+			continue
 		}
 		tpValueElem.FieldByName(fd.GoName).
 			Set(valElems[i])
@@ -93,3 +112,7 @@ func (r reflectValue) SetColumns(rows *sql.Rows) error {
 	return nil
 }
 
+func (r reflectValue) ignoreUnknownColumns() Value {
+	r.ignoreUnknown = true
+	return r
+}
@@ -2,18 +2,107 @@ package valuer
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
 	"gitee.com/geektime-geekbang/geektime-go/orm/model"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func Test_reflectValue_SetColumns(t *testing.T) {
 	testSetColumns(t, NewReflectValue)
 }
 
+func Test_reflectValue_IgnoreUnknownColumns(t *testing.T) {
+	testIgnoreUnknownColumns(t, NewReflectValue)
+}
+
+func testIgnoreUnknownColumns(t *testing.T, creator Creator) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mockRows := sqlmock.NewRows([]string{"avg_age", "id", "cnt", "first_name"})
+	mockRows.AddRow("18.5", "1", "10", "Tom")
+	mock.ExpectQuery("SELECT XX").WillReturnRows(mockRows)
+	rows, err := mockDB.Query("SELECT XX")
+	require.NoError(t, err)
+	rows.Next()
+
+	tm := &TestModel{}
+	m, err := model.NewRegistry().Get(tm)
+	require.NoError(t, err)
+	err = IgnoreUnknownColumns(creator)(m, tm).SetColumns(rows)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, tm)
+}
+
+func Test_reflectValue_SetField(t *testing.T) {
+	testSetField(t, NewReflectValue)
+}
+
+func testSetField(t *testing.T, creator Creator) {
+	testCases := []struct {
+		name  string
+		field string
+		val   any
+
+		wantErr    error
+		wantEntity *SetFieldModel
+	}{
+		{
+			name:       "same type",
+			field:      "Id",
+			val:        int64(12),
+			wantEntity: &SetFieldModel{BaseModel: BaseModel{Id: 12}},
+		},
+		{
+			name:       "convert",
+			field:      "Uid",
+			val:        int64(12),
+			wantEntity: &SetFieldModel{Uid: 12},
+		},
+		{
+			name:       "pointer",
+			field:      "Age",
+			val:        int64(18),
+			wantEntity: &SetFieldModel{Age: func() *int { a := 18; return &a }()},
+		},
+		{
+			name:    "invalid type",
+			field:   "Name",
+			val:     int64(12),
+			wantErr: errs.NewErrInvalidFieldValue("string", int64(12)),
+		},
+		{
+			name:    "unknown field",
+			field:   "XXX",
+			val:     int64(12),
+			wantErr: errs.NewErrUnknownField("XXX"),
+		},
+	}
+	r := model.NewRegistry()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entity := &SetFieldModel{}
+			m, err := r.Get(entity)
+			require.NoError(t, err)
+			err = creator(m, entity).SetField(tc.field, tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantEntity, entity)
+		})
+	}
+}
+
 func testSetColumns(t *testing.T, creator Creator) {
+	now := time.UnixMilli(1000)
+	age := 18
 	testCases := []struct {
 		name string
 		// 一定是指针
@@ -70,6 +159,36 @@ func testSetColumns(t *testing.T, creator Creator) {
 				LastName:  &sql.NullString{Valid: true, String: "Jerry"},
 			},
 		},
+
+		{
+			name: "embedded and nullable",
+			entity: &FullModel{},
+			rows: func() *sqlmock.Rows {
+				rows := sqlmock.NewRows([]string{"id", "created_at", "age", "score", "nick_name", "email", "code"})
+				rows.AddRow("1", now, "18", nil, "Tom", nil, "abc")
+				return rows
+			},
+			wantEntity: &FullModel{
+				BaseModel: BaseModel{
+					Id:        1,
+					CreatedAt: now,
+				},
+				Age:      &age,
+				NickName: sql.NullString{Valid: true, String: "Tom"},
+				Code:     Code("abc"),
+			},
+		},
+
+		{
+			name: "unknown column",
+			entity: &TestModel{},
+			rows: func() *sqlmock.Rows {
+				rows := sqlmock.NewRows([]string{"id", "avg_age"})
+				rows.AddRow("1", "18.5")
+				return rows
+			},
+			wantErr: errs.NewErrUnknownColumn("avg_age"),
+		},
 	}
 
 	r := model.NewRegistry()
@@ -107,4 +226,43 @@ type TestModel struct {
 	FirstName string
 	Age       int8
 	LastName  *sql.NullString
-}
\ No newline at end of file
+}
+
+type BaseModel struct {
+	Id        int64
+	CreatedAt time.Time
+}
+
+type SetFieldModel struct {
+	BaseModel
+	Uid  uint32
+	Age  *int
+	Name string
+}
+
+type FullModel struct {
+	BaseModel
+	Age      *int
+	Score    *float64
+	NickName sql.NullString
+	Email    *sql.NullString
+	Code     Code
+	Ignore   string `orm:"-"`
+}
+
+// Code 自定义类型，实现了 driver.Valuer 和 sql.Scanner
+type Code string
+
+func (c Code) Value() (driver.Value, error) {
+	return string(c), nil
+}
+
+func (c *Code) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		*c = Code(v)
+	case []byte:
+		*c = Code(v)
+	}
+	return nil
+}
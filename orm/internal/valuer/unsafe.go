@@ -12,11 +12,16 @@ type unsafeValue struct {
 	model *model.Model
 	// 起始地址
 	address unsafe.Pointer
+	// ignoreUnknown 为 true 的时候，丢弃模型里面没有的列
+	ignoreUnknown bool
 }
 
 var _ Creator = NewUnsafeValue
 
 func NewUnsafeValue(model *model.Model, val any) Value {
+	if g, ok := val.(Generated); ok {
+		return g.OrmValue(model, false)
+	}
 	address := reflect.ValueOf(val).UnsafePointer()
 	return unsafeValue{
 		model: model,
@@ -38,6 +43,15 @@ func (r unsafeValue) Field(name string) (any, error) {
 	return val.Elem().Interface(), nil
 }
 
+func (r unsafeValue) SetField(name string, val any) error {
+	fd, ok := r.model.FieldMap[name]
+	if !ok {
+		return errs.NewErrUnknownField(name)
+	}
+	fdAddress := unsafe.Pointer(uintptr(r.address) + fd.Offset)
+	return setValue(reflect.NewAt(fd.Type, fdAddress).Elem(), val)
+}
+
 func (r unsafeValue) SetColumns(rows *sql.Rows) error {
 	// 我怎么知道你 SELECT 出来了哪些列？
 	// 拿到了 SELECT 出来的列
@@ -53,7 +67,12 @@ func (r unsafeValue) SetColumns(rows *sql.Rows) error {
 		// c 是列名
 		fd, ok := r.model.ColumnMap[c]
 		if !ok {
-			return errs.NewErrUnknownColumn(c)
+			if !r.ignoreUnknown {
+				return errs.NewErrUnknownColumn(c)
+			}
+			// 随便找个地方接收这一列，后面不会用到
+			vals = append(vals, new(any))
+			continue
 		}
 		// 是不是要计算字段的地址？
 		// 起始地址 + 偏移量
@@ -69,3 +88,8 @@ func (r unsafeValue) SetColumns(rows *sql.Rows) error {
 	err = rows.Scan(vals...)
 	return err
 }
+
+func (r unsafeValue) ignoreUnknownColumns() Value {
+	r.ignoreUnknown = true
+	return r
+}
@@ -5,3 +5,11 @@ import "testing"
 func Test_unsafeValue_SetColumns(t *testing.T) {
 	testSetColumns(t, NewUnsafeValue)
 }
+
+func Test_unsafeValue_IgnoreUnknownColumns(t *testing.T) {
+	testIgnoreUnknownColumns(t, NewUnsafeValue)
+}
+
+func Test_unsafeValue_SetField(t *testing.T) {
+	testSetField(t, NewUnsafeValue)
+}
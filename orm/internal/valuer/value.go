@@ -2,16 +2,75 @@ package valuer
 
 import (
 	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
 	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"reflect"
 )
 
 type Value interface {
 	Field(name string) (any, error)
+	// SetField 设置字段的值，val 的类型要能转换为字段的类型
+	SetField(name string, val any) error
 	SetColumns(rows *sql.Rows) error
 }
 
 type Creator func(model *model.Model, entity any) Value
 
+// Generated 一般是 ormgen 生成的，实现了这个接口的模型
+// 读写字段都不需要反射或者 unsafe
+type Generated interface {
+	OrmValue(model *model.Model, ignoreUnknown bool) Value
+}
+
+// IgnoreUnknownColumns 包装 c，结果集里面有模型没有的列时直接丢弃，
+// 而不是返回 errs.NewErrUnknownColumn
+func IgnoreUnknownColumns(c Creator) Creator {
+	return func(model *model.Model, entity any) Value {
+		if g, ok := entity.(Generated); ok {
+			return g.OrmValue(model, true)
+		}
+		val := c(model, entity)
+		if iv, ok := val.(ignorable); ok {
+			return iv.ignoreUnknownColumns()
+		}
+		return val
+	}
+}
+
+// SetFieldValue 类型一样的时候直接赋值，不一样的时候才用反射转换
+func SetFieldValue[T any](dst *T, val any) error {
+	if v, ok := val.(T); ok {
+		*dst = v
+		return nil
+	}
+	return setValue(reflect.ValueOf(dst).Elem(), val)
+}
+
+// setValue 把 val 设置到 dst 上，会做类型转换，例如 int64 转 int
+// dst 是指针的话会创建一个新的实例
+func setValue(dst reflect.Value, val any) error {
+	src := reflect.ValueOf(val)
+	if dst.Kind() == reflect.Pointer && src.Kind() != reflect.Pointer {
+		ptr := reflect.New(dst.Type().Elem())
+		if err := setValue(ptr.Elem(), val); err != nil {
+			return err
+		}
+		dst.Set(ptr)
+		return nil
+	}
+	// int64 可以转换为 string，但是结果是字符，不是我们想要的
+	if !src.Type().ConvertibleTo(dst.Type()) ||
+		(dst.Kind() == reflect.String && src.Kind() != reflect.String) {
+		return errs.NewErrInvalidFieldValue(dst.Type().String(), val)
+	}
+	dst.Set(src.Convert(dst.Type()))
+	return nil
+}
+
+type ignorable interface {
+	ignoreUnknownColumns() Value
+}
+
 type ValuerV1 interface {
 	SetColumns(entity any, rows sql.Rows) error
 }
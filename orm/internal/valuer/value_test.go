@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"gitee.com/geektime-geekbang/geektime-go/orm/model"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -47,4 +48,60 @@ func BenchmarkSetColumns(b *testing.B) {
 	})
 }
 
+func TestGenerated(t *testing.T) {
+	m := &model.Model{}
+	testCases := []struct {
+		name    string
+		creator Creator
+		want    Value
+	}{
+		{
+			name:    "reflect",
+			creator: NewReflectValue,
+			want:    generatedValue{model: m},
+		},
+		{
+			name:    "unsafe",
+			creator: NewUnsafeValue,
+			want:    generatedValue{model: m},
+		},
+		{
+			name:    "ignore unknown columns",
+			creator: IgnoreUnknownColumns(NewUnsafeValue),
+			want:    generatedValue{model: m, ignoreUnknown: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.creator(m, &generatedModel{}))
+		})
+	}
+}
+
+func TestSetFieldValue(t *testing.T) {
+	var id int
+	require.NoError(t, SetFieldValue(&id, 12))
+	assert.Equal(t, 12, id)
+	// 类型不一样的时候会转换
+	require.NoError(t, SetFieldValue(&id, int64(13)))
+	assert.Equal(t, 13, id)
+
+	var name *string
+	require.NoError(t, SetFieldValue(&name, "Tom"))
+	assert.Equal(t, "Tom", *name)
+	assert.Error(t, SetFieldValue(&name, 12))
+}
 
+type generatedModel struct {
+	Id int64
+}
+
+func (g *generatedModel) OrmValue(m *model.Model, ignoreUnknown bool) Value {
+	return generatedValue{model: m, ignoreUnknown: ignoreUnknown}
+}
+
+type generatedValue struct {
+	Value
+	model         *model.Model
+	ignoreUnknown bool
+}
@@ -0,0 +1,70 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/valuer"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+)
+
+// Iterator 是结果集的迭代器，用法和 sql.Rows 类似：
+//
+//	it := NewSelector[User](db).Stream(ctx)
+//	defer it.Close()
+//	for it.Next() {
+//		u := it.Item()
+//	}
+//	err := it.Err()
+type Iterator[T any] struct {
+	ctx     context.Context
+	rows    *sql.Rows
+	model   *model.Model
+	creator valuer.Creator
+
+	cur *T
+	err error
+}
+
+// Next 将迭代器往前推进一行，并且把这一行映射为 T
+// 返回 false 的时候，要通过 Err 确认是遍历完了还是出错了
+func (i *Iterator[T]) Next() bool {
+	if i.err != nil || i.rows == nil {
+		return false
+	}
+	if !i.rows.Next() {
+		i.cur = nil
+		return false
+	}
+	tp, err := scanRow[T](i.ctx, i.rows, i.model, i.creator)
+	if err != nil {
+		i.err = err
+		i.cur = nil
+		return false
+	}
+	i.cur = tp
+	return true
+}
+
+// Item 返回当前行，只有在 Next 返回 true 之后调用才有意义
+func (i *Iterator[T]) Item() *T {
+	return i.cur
+}
+
+// Err 返回构造查询、发起查询、映射结果或者遍历过程中出现的错误
+func (i *Iterator[T]) Err() error {
+	if i.err != nil {
+		return i.err
+	}
+	if i.rows != nil {
+		return i.rows.Err()
+	}
+	return nil
+}
+
+// Close 关闭底层的 sql.Rows，可以重复调用
+func (i *Iterator[T]) Close() error {
+	if i.rows == nil {
+		return nil
+	}
+	return i.rows.Close()
+}
@@ -0,0 +1,50 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Handler 处理一次查询
+type Handler func(ctx context.Context, qc *QueryContext) *QueryResult
+
+// Middleware 函数式的洋葱模式，和 web.Middleware 是一样的
+// 可以在这里接入日志、监控、链路追踪、缓存等
+type Middleware func(next Handler) Handler
+
+// QueryResult 代表查询的结果
+type QueryResult struct {
+	// Result 在不同的查询里面，类型是不同的
+	// Selector.Get 是 *T
+	// Selector.GetMulti 是 []*T
+	// Selector.Stream 是 *sql.Rows
+	// INSERT、UPDATE 和 DELETE 是 sql.Result
+	Result any
+	Err    error
+}
+
+// handle 让 qc 依次经过所有的 middleware，最后交给 handler 处理
+func (c core) handle(ctx context.Context, qc *QueryContext, handler Handler) *QueryResult {
+	qc.Tx = c.tx
+	root := handler
+	for i := len(c.mdls) - 1; i >= 0; i-- {
+		root = c.mdls[i](root)
+	}
+	return root(ctx, qc)
+}
+
+// exec 通过 middleware 执行 INSERT、UPDATE 和 DELETE 语句
+func exec(ctx context.Context, sess Session, qc *QueryContext) Result {
+	res := sess.getCore().handle(ctx, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		r, err := sess.execContext(ctx, qc.Query.SQL, qc.Query.Args...)
+		return &QueryResult{
+			Result: r,
+			Err:    err,
+		}
+	})
+	sqlRes, _ := res.Result.(sql.Result)
+	return Result{
+		err: res.Err,
+		res: sqlRes,
+	}
+}
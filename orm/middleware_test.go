@@ -0,0 +1,153 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	var logs []string
+	mdl := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, qc *QueryContext) *QueryResult {
+				logs = append(logs, name+" before "+qc.Type+" "+qc.Model.TableName+" "+qc.Query.SQL)
+				res := next(ctx, qc)
+				logs = append(logs, name+" after")
+				return res
+			}
+		}
+	}
+	db, err := OpenDB(mockDB, DBWithMiddlewares(mdl("first"), mdl("second")))
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"id", "first_name"})
+	rows.AddRow(1, "Tom")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	res, err := NewSelector[TestModel](db).Where(C("Id").Eq(1)).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+	assert.Equal(t, []string{
+		"first before SELECT test_model SELECT * FROM `test_model` WHERE `id` = ?;",
+		"second before SELECT test_model SELECT * FROM `test_model` WHERE `id` = ?;",
+		"second after",
+		"first after",
+	}, logs)
+
+	logs = nil
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 3))
+	affected, err := NewDeleter[TestModel](db).Where(C("Id").Eq(1)).
+		Exec(context.Background()).RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	assert.Equal(t, []string{
+		"first before DELETE test_model DELETE FROM `test_model` WHERE `id` = ?;",
+		"second before DELETE test_model DELETE FROM `test_model` WHERE `id` = ?;",
+		"second after",
+		"first after",
+	}, logs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddleware_Interrupt(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	// 不调用 next，直接返回结果，不会发起查询
+	db, err := OpenDB(mockDB, DBWithMiddlewares(func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			if qc.Type == "SELECT" {
+				return &QueryResult{
+					Result: []*TestModel{{Id: 12}},
+				}
+			}
+			return &QueryResult{
+				Err: errors.New("read only"),
+			}
+		}
+	}))
+	require.NoError(t, err)
+
+	res, err := NewSelector[TestModel](db).GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{{Id: 12}}, res)
+
+	err = NewInserter[TestModel](db).Values(&TestModel{}).Exec(context.Background()).Err()
+	assert.Equal(t, errors.New("read only"), err)
+
+	err = NewUpdater[TestModel](db).Update(&TestModel{Age: 1}).Exec(context.Background()).Err()
+	assert.Equal(t, errors.New("read only"), err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddleware_Tx(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	cnt := 0
+	db, err := OpenDB(mockDB, DBWithMiddlewares(func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			cnt++
+			return next(ctx, qc)
+		}
+	}))
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err = db.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+		return NewUpdater[TestModel](tx).Update(&TestModel{Age: 18}).Exec(ctx).Err()
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddleware_ArgColumns(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	var cols [][]string
+	mdl := func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			cols = append(cols, qc.ArgColumns)
+			return next(ctx, qc)
+		}
+	}
+	db, err := OpenDB(mockDB, DBWithMiddlewares(mdl))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sub := NewSelector[TestModel](db).Select(C("Id")).
+		Where(C("FirstName").Eq("Tom")).AsSubquery("sub")
+	_, err = NewSelector[TestModel](db).
+		Where(C("Id").InQuery(sub), C("Age").Add(1).Gt(18)).
+		Limit(10).Get(context.Background())
+	require.NoError(t, err)
+
+	mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+	err = NewInserter[TestModel](db).Columns("Id", "FirstName").
+		Values(&TestModel{Id: 1, FirstName: "Tom"}).Exec(context.Background()).Err()
+	require.NoError(t, err)
+
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	err = NewUpdater[TestModel](db).Update(&TestModel{FirstName: "Jerry"}).
+		Set(C("FirstName"), Assign("Age", 18)).
+		Where(C("Id").Eq(1)).Exec(context.Background()).Err()
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"first_name", "age", "", ""},
+		{"id", "first_name"},
+		{"first_name", "age", "id"},
+	}, cols)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
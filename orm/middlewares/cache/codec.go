@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/valuer"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"reflect"
+)
+
+var errInvalidData = errors.New("cache: 缓存的数据和结果类型对不上")
+
+// encode 把 *T 或者 []*T 编码成字节。
+// 每一行按照模型的字段，通过 valuer 一个一个读出来，再用 gob 编码，
+// 所以 json:"-" 和没有导出的字段也会被缓存，取出来的和直接查询的结果是一样的。
+// m 为 nil 的时候 T 是基本类型，直接编码
+func encode(m *model.Model, res any) ([]byte, error) {
+	val := reflect.ValueOf(res)
+	ptrs := []reflect.Value{val}
+	if val.Kind() == reflect.Slice {
+		ptrs = make([]reflect.Value, 0, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			ptrs = append(ptrs, val.Index(i))
+		}
+	}
+	rows := make([][][]byte, 0, len(ptrs))
+	for _, ptr := range ptrs {
+		row, err := encodeRow(m, ptr)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeRow(m *model.Model, ptr reflect.Value) ([][]byte, error) {
+	if m == nil {
+		data, err := encodeValue(ptr.Elem())
+		return [][]byte{data}, err
+	}
+	v := valuer.NewUnsafeValue(m, ptr.Interface())
+	row := make([][]byte, 0, len(m.Fields))
+	for _, fd := range m.Fields {
+		fdVal, err := v.Field(fd.GoName)
+		if err != nil {
+			return nil, err
+		}
+		data, err := encodeValue(reflect.ValueOf(fdVal))
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, data)
+	}
+	return row, nil
+}
+
+// encodeValue gob 没法区分 nil 和零值，所以 nil 编码成 nil，其余的值都不会是 nil
+func encodeValue(val reflect.Value) ([]byte, error) {
+	if !val.IsValid() {
+		return nil, nil
+	}
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		if val.IsNil() {
+			return nil, nil
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).EncodeValue(val); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode 是 encode 的逆过程，typ 是 *T 或者 []*T
+func decode(m *model.Model, typ reflect.Type, data []byte) (any, error) {
+	var rows [][][]byte
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&rows); err != nil {
+		return nil, err
+	}
+	if typ.Kind() != reflect.Slice {
+		if len(rows) != 1 {
+			return nil, errInvalidData
+		}
+		ptr, err := decodeRow(m, typ, rows[0])
+		if err != nil {
+			return nil, err
+		}
+		return ptr.Interface(), nil
+	}
+	res := reflect.MakeSlice(typ, 0, len(rows))
+	for _, row := range rows {
+		ptr, err := decodeRow(m, typ.Elem(), row)
+		if err != nil {
+			return nil, err
+		}
+		res = reflect.Append(res, ptr)
+	}
+	return res.Interface(), nil
+}
+
+// decodeRow typ 是 *T
+func decodeRow(m *model.Model, typ reflect.Type, row [][]byte) (reflect.Value, error) {
+	ptr := reflect.New(typ.Elem())
+	if m == nil {
+		if len(row) != 1 {
+			return reflect.Value{}, errInvalidData
+		}
+		return ptr, decodeValue(ptr, row[0])
+	}
+	if len(row) != len(m.Fields) {
+		return reflect.Value{}, errInvalidData
+	}
+	v := valuer.NewUnsafeValue(m, ptr.Interface())
+	for i, fd := range m.Fields {
+		// 新建的 T 里面本来就是零值
+		if len(row[i]) == 0 {
+			continue
+		}
+		fdPtr := reflect.New(fd.Type)
+		if err := decodeValue(fdPtr, row[i]); err != nil {
+			return reflect.Value{}, err
+		}
+		if err := v.SetField(fd.GoName, fdPtr.Elem().Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return ptr, nil
+}
+
+// decodeValue data 为空的时候 ptr 指向的就是零值
+func decodeValue(ptr reflect.Value, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return gob.NewDecoder(bytes.NewReader(data)).DecodeValue(ptr)
+}
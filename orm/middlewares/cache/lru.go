@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	lru "github.com/hashicorp/golang-lru"
+	"strings"
+	"time"
+)
+
+var _ Cache = &LRUCache{}
+
+// LRUCache 基于 golang-lru 的本地缓存
+type LRUCache struct {
+	cache *lru.Cache
+}
+
+func NewLRUCache(size int) (*LRUCache, error) {
+	c, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &LRUCache{
+		cache: c,
+	}, nil
+}
+
+type lruItem struct {
+	val []byte
+	// 零值代表永不过期
+	deadline time.Time
+}
+
+func (l *LRUCache) Get(ctx context.Context, key string) ([]byte, error) {
+	val, ok := l.cache.Get(key)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	item := val.(lruItem)
+	// 过期了就顺手删掉
+	if !item.deadline.IsZero() && item.deadline.Before(time.Now()) {
+		l.cache.Remove(key)
+		return nil, ErrKeyNotFound
+	}
+	return item.val, nil
+}
+
+func (l *LRUCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	item := lruItem{val: val}
+	if expiration > 0 {
+		item.deadline = time.Now().Add(expiration)
+	}
+	l.cache.Add(key, item)
+	return nil
+}
+
+func (l *LRUCache) DeletePrefix(ctx context.Context, prefix string) error {
+	for _, key := range l.cache.Keys() {
+		if strings.HasPrefix(key.(string), prefix) {
+			l.cache.Remove(key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c, err := NewLRUCache(2)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = c.Get(ctx, "key1")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	require.NoError(t, c.Set(ctx, "user:1", []byte("tom"), 0))
+	val, err := c.Get(ctx, "user:1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("tom"), val)
+
+	// 过期
+	require.NoError(t, c.Set(ctx, "user:2", []byte("jerry"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = c.Get(ctx, "user:2")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 超过容量，最久没用的被淘汰
+	require.NoError(t, c.Set(ctx, "order:1", []byte("1"), 0))
+	require.NoError(t, c.Set(ctx, "order:2", []byte("2"), 0))
+	_, err = c.Get(ctx, "user:1")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	require.NoError(t, c.DeletePrefix(ctx, "order:"))
+	_, err = c.Get(ctx, "order:1")
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = c.Get(ctx, "order:2")
+	assert.Equal(t, ErrKeyNotFound, err)
+}
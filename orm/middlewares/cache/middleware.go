@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"reflect"
+	"time"
+)
+
+var rowsType = reflect.TypeOf(&sql.Rows{})
+
+// MiddlewareBuilder 缓存查询结果
+// key 是 prefix:表名:hash(结果类型 + SQL + 参数)，
+// 同一张表上的 INSERT、UPDATE 和 DELETE 会让这张表的缓存全部失效。
+// 用到了多张表的查询，例如 JOIN 和子查询，结果放在第一张表下面，
+// 其余的表下面各放一个同名的空标记，任意一张表失效之后标记就没了，也就不会命中。
+// 原生查询不知道用到了哪些表，事务里面的查询可能读到之后被回滚的数据，
+// 所以这两种都不会被缓存。事务里面的写操作在执行的时候会让缓存失效，
+// 提交之后还会再失效一次，因为提交之前别的查询依旧可能把旧数据放回缓存。
+// 结果按照模型的字段序列化，参考 encode，
+// AfterQuery 钩子在命中缓存的时候不会执行
+type MiddlewareBuilder struct {
+	cache      Cache
+	prefix     string
+	expiration time.Duration
+}
+
+func NewMiddlewareBuilder(c Cache) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		cache:      c,
+		prefix:     "orm",
+		expiration: time.Minute,
+	}
+}
+
+func (m *MiddlewareBuilder) Prefix(prefix string) *MiddlewareBuilder {
+	m.prefix = prefix
+	return m
+}
+
+func (m *MiddlewareBuilder) Expiration(expiration time.Duration) *MiddlewareBuilder {
+	m.expiration = expiration
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() orm.Middleware {
+	return func(next orm.Handler) orm.Handler {
+		return func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+			if qc.Model == nil || qc.Query == nil {
+				return next(ctx, qc)
+			}
+			if qc.Type != "SELECT" {
+				res := next(ctx, qc)
+				// 即便出错了，也可能有一部分数据被修改了，所以都要删除缓存。
+				// 删除缓存失败也不影响写操作本身的结果，只能等缓存过期
+				// Deleter.From 指定的表可能不是模型对应的表
+				tables := qc.Tables
+				if len(tables) == 0 {
+					tables = []string{qc.Model.TableName}
+				}
+				m.invalidate(ctx, tables)
+				if qc.Tx != nil {
+					qc.Tx.AfterCommit(func() {
+						m.invalidate(context.Background(), tables)
+					})
+				}
+				return res
+			}
+			// Stream 返回的 *sql.Rows 是没法缓存的
+			if qc.ResultType == nil || qc.ResultType == rowsType ||
+				len(qc.Tables) == 0 || qc.Tx != nil {
+				return next(ctx, qc)
+			}
+
+			keys, err := m.keys(qc)
+			if err != nil {
+				return next(ctx, qc)
+			}
+			if data, ok := m.get(ctx, keys); ok {
+				if val, err := decode(qc.ResultModel, qc.ResultType, data); err == nil {
+					return &orm.QueryResult{Result: val}
+				}
+			}
+
+			res := next(ctx, qc)
+			if res.Err != nil {
+				return res
+			}
+			if data, err := encode(qc.ResultModel, res.Result); err == nil {
+				m.set(ctx, keys, data)
+			}
+			return res
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) invalidate(ctx context.Context, tables []string) {
+	for _, t := range tables {
+		_ = m.cache.DeletePrefix(ctx, m.tablePrefix(t))
+	}
+}
+
+func (m *MiddlewareBuilder) tablePrefix(table string) string {
+	return m.prefix + ":" + table + ":"
+}
+
+// keys 第一个是放结果的 key，后面的是其余表下面的标记
+func (m *MiddlewareBuilder) keys(qc *orm.QueryContext) ([]string, error) {
+	args, err := json.Marshal(qc.Query.Args)
+	if err != nil {
+		return nil, err
+	}
+	// Get 和 GetMulti 的 SQL 是一样的，所以要把结果类型也带上
+	h := md5.New()
+	h.Write([]byte(qc.ResultType.String()))
+	h.Write([]byte(qc.Query.SQL))
+	h.Write(args)
+	hash := hex.EncodeToString(h.Sum(nil))
+	keys := make([]string, 0, len(qc.Tables))
+	for _, t := range qc.Tables {
+		keys = append(keys, m.tablePrefix(t)+hash)
+	}
+	return keys, nil
+}
+
+// get 结果和所有的标记都在才算命中
+func (m *MiddlewareBuilder) get(ctx context.Context, keys []string) ([]byte, bool) {
+	data, err := m.cache.Get(ctx, keys[0])
+	if err != nil {
+		return nil, false
+	}
+	for _, key := range keys[1:] {
+		if _, err = m.cache.Get(ctx, key); err != nil {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// set 先放标记再放结果，这样不会出现只有结果没有标记的情况
+func (m *MiddlewareBuilder) set(ctx context.Context, keys []string, data []byte) {
+	for _, key := range keys[1:] {
+		if err := m.cache.Set(ctx, key, []byte{}, m.expiration); err != nil {
+			return
+		}
+	}
+	_ = m.cache.Set(ctx, keys[0], data, m.expiration)
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	c, err := NewLRUCache(16)
+	require.NoError(t, err)
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(NewMiddlewareBuilder(c).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 第一次查询数据库，第二次命中缓存
+	mock.ExpectQuery("SELECT .*").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+	for i := 0; i < 2; i++ {
+		u, err := orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &User{Id: 1, Name: "Tom"}, u)
+	}
+
+	// 同样的 SQL，但是 GetMulti 的结果类型不同，不会用 Get 的缓存
+	mock.ExpectQuery("SELECT .*").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+	for i := 0; i < 2; i++ {
+		us, err := orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).GetMulti(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []*User{{Id: 1, Name: "Tom"}}, us)
+	}
+
+	// 参数不同，不会命中
+	mock.ExpectQuery("SELECT .*").WithArgs(2).WillReturnError(orm.ErrNoRows)
+	_, err = orm.NewSelector[User](db).Where(orm.C("Id").Eq(2)).Get(ctx)
+	assert.Equal(t, orm.ErrNoRows, err)
+
+	// 写操作之后缓存失效
+	mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(2, 1))
+	err = orm.NewInserter[User](db).Values(&User{Id: 2, Name: "Jerry"}).Exec(ctx).Err()
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT .*").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+	_, err = orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+
+	// 删除的是 From 指定的表，而不是 Deleter 模型对应的表
+	mock.ExpectExec("DELETE FROM `user`.*").WillReturnResult(sqlmock.NewResult(0, 1))
+	err = orm.NewDeleter[Order](db).From(orm.TableOf(&User{})).
+		Where(orm.C("Id").Eq(1)).Exec(ctx).Err()
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT .*").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+	_, err = orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+
+	// Stream 不走缓存
+	mock.ExpectQuery("SELECT .*").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+	it := orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).Stream(ctx)
+	require.True(t, it.Next())
+	assert.Equal(t, &User{Id: 1, Name: "Tom"}, it.Item())
+	require.NoError(t, it.Close())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_Codec(t *testing.T) {
+	c, err := NewLRUCache(16)
+	require.NoError(t, err)
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(NewMiddlewareBuilder(c).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	createdAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	cols := []string{"id", "secret", "nickname", "score", "avatar", "created_at", "remark"}
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows(cols).
+		AddRow(1, "abc", "Tom", 0, []byte("png"), createdAt, "ok").
+		AddRow(2, "", nil, nil, nil, createdAt, nil))
+	want := []*Profile{
+		{Id: 1, Secret: "abc", Nickname: &sql.NullString{String: "Tom", Valid: true},
+			score: new(int), Avatar: []byte("png"), CreatedAt: createdAt,
+			Remark: sql.NullString{String: "ok", Valid: true}},
+		{Id: 2, CreatedAt: createdAt},
+	}
+	// 第二次命中缓存，json:"-"、没有导出的字段、指向零值的指针和 nil 都和查出来的一样
+	for i := 0; i < 2; i++ {
+		ps, err := orm.NewSelector[Profile](db).GetMulti(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, want, ps)
+	}
+
+	// 基本类型的结果
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(2))
+	for i := 0; i < 2; i++ {
+		cnt, err := orm.GetAs[int64](ctx, orm.NewSelector[Profile](db).Select(orm.Count("Id")))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), *cnt)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_MultiTables(t *testing.T) {
+	c, err := NewLRUCache(16)
+	require.NoError(t, err)
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(NewMiddlewareBuilder(c).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	join := func() ([]*User, error) {
+		u := orm.TableOf(&User{}).As("u")
+		o := orm.TableOf(&Order{}).As("o")
+		return orm.NewSelector[User](db).Select(u.C("Id"), u.C("Name")).
+			From(u.Join(o).On(u.C("Id").Eq(o.C("UserId")))).GetMulti(ctx)
+	}
+	sub := func() ([]*User, error) {
+		orders := orm.NewSelector[Order](db).Select(orm.C("UserId")).AsSubquery("sub")
+		return orm.NewSelector[User](db).Where(orm.C("Id").InQuery(orders)).GetMulti(ctx)
+	}
+	queries := []func() ([]*User, error){join, sub}
+	for _, query := range queries {
+		mock.ExpectQuery("SELECT .*").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+		for i := 0; i < 2; i++ {
+			us, err := query()
+			require.NoError(t, err)
+			assert.Equal(t, []*User{{Id: 1, Name: "Tom"}}, us)
+		}
+	}
+
+	// 写了 JOIN 和子查询里面的表，两个查询的缓存都会失效
+	mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+	err = orm.NewInserter[Order](db).Values(&Order{Id: 1, UserId: 2}).Exec(ctx).Err()
+	require.NoError(t, err)
+	for _, query := range queries {
+		mock.ExpectQuery("SELECT .*").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Jerry"))
+		us, err := query()
+		require.NoError(t, err)
+		assert.Equal(t, []*User{{Id: 2, Name: "Jerry"}}, us)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_Skip(t *testing.T) {
+	c, err := NewLRUCache(16)
+	require.NoError(t, err)
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(NewMiddlewareBuilder(c).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 原生查询不走缓存
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("SELECT .*").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+		_, err = orm.RawQuery[User](db, "SELECT * FROM `user` WHERE `id` = ?", 1).Get(ctx)
+		require.NoError(t, err)
+	}
+
+	// 事务里面的查询不走缓存
+	mock.ExpectBegin()
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("SELECT .*").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+	}
+	mock.ExpectRollback()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = orm.NewSelector[User](tx).Where(orm.C("Id").Eq(1)).Get(ctx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_Commit(t *testing.T) {
+	c, err := NewLRUCache(16)
+	require.NoError(t, err)
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(NewMiddlewareBuilder(c).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+	get := func() (*User, error) {
+		return orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).Get(ctx)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	// 提交之前，事务外面的查询读到旧数据，放回了缓存
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+	mock.ExpectCommit()
+	// 提交之后缓存又失效了一次，所以能读到新数据
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Jerry"))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	err = orm.NewUpdater[User](tx).Update(&User{Name: "Jerry"}).
+		Set(orm.C("Name")).Where(orm.C("Id").Eq(1)).Exec(ctx).Err()
+	require.NoError(t, err)
+	u, err := get()
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", u.Name)
+	u, err = get()
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", u.Name)
+	require.NoError(t, tx.Commit())
+	u, err = get()
+	require.NoError(t, err)
+	assert.Equal(t, "Jerry", u.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type User struct {
+	Id   int64
+	Name string
+}
+
+type Order struct {
+	Id     int64
+	UserId int64
+}
+
+type Profile struct {
+	Id       int64
+	Secret   string `json:"-"`
+	Nickname *sql.NullString
+	// score 没有导出，只有 unsafe 的 valuer 能读写
+	score     *int
+	Avatar    []byte
+	CreatedAt time.Time
+	Remark    sql.NullString
+}
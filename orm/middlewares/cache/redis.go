@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"github.com/go-redis/redis/v9"
+	"time"
+)
+
+var _ Cache = &RedisCache{}
+
+type RedisCache struct {
+	client redis.Cmdable
+	// scanCount 是 DeletePrefix 每次 SCAN 的数量
+	scanCount int64
+}
+
+func NewRedisCache(client redis.Cmdable) *RedisCache {
+	return &RedisCache{
+		client:    client,
+		scanCount: 100,
+	}
+}
+
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrKeyNotFound
+	}
+	return val, err
+}
+
+func (r *RedisCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	if expiration < 0 {
+		expiration = 0
+	}
+	return r.client.Set(ctx, key, val, expiration).Err()
+}
+
+// DeletePrefix 用 SCAN 而不是 KEYS，避免阻塞 redis
+func (r *RedisCache) DeletePrefix(ctx context.Context, prefix string) error {
+	iter := r.client.Scan(ctx, 0, prefix+"*", r.scanCount).Iterator()
+	keys := make([]string, 0, r.scanCount)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if int64(len(keys)) >= r.scanCount {
+			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
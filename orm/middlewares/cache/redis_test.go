@@ -0,0 +1,33 @@
+//go:build e2e
+
+package cache
+
+import (
+	"context"
+	"github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// 要先启动 docker-compose 里面的 redis
+func TestRedisCache(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewRedisCache(rdb)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "orm:user:1", []byte("tom"), time.Minute))
+	require.NoError(t, c.Set(ctx, "orm:user:2", []byte("jerry"), time.Minute))
+	val, err := c.Get(ctx, "orm:user:1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("tom"), val)
+
+	require.NoError(t, c.DeletePrefix(ctx, "orm:user:"))
+	_, err = c.Get(ctx, "orm:user:1")
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = c.Get(ctx, "orm:user:2")
+	assert.Equal(t, ErrKeyNotFound, err)
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrKeyNotFound = errors.New("cache: key 不存在")
+)
+
+// Cache 存放查询结果的缓存
+// 值都是序列化之后的数据，这样本地缓存和 redis 之类的远程缓存可以用同一套逻辑
+type Cache interface {
+	// Get 找不到的时候返回 ErrKeyNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set expiration 小于等于 0 代表永不过期
+	Set(ctx context.Context, key string, val []byte, expiration time.Duration) error
+	// DeletePrefix 删除所有以 prefix 开头的 key，写操作之后用它让整张表的缓存失效
+	DeletePrefix(ctx context.Context, prefix string) error
+}
@@ -0,0 +1,57 @@
+package opentelemetry
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "gitee.com/geektime-geekbang/geektime-go/orm/middlewares/opentelemetry"
+
+type MiddlewareBuilder struct {
+	Tracer trace.Tracer
+}
+
+func (m MiddlewareBuilder) Build() orm.Middleware {
+	if m.Tracer == nil {
+		m.Tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	}
+	return func(next orm.Handler) orm.Handler {
+		return func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+			tbl := ""
+			if qc.Model != nil {
+				tbl = qc.Model.TableName
+			}
+			// span 的名字类似于 SELECT user
+			// 如果 ctx 里面已经有了 span，例如 web 那边的，这里就会作为它的子 span
+			ctx, span := m.Tracer.Start(ctx, qc.Type+" "+tbl,
+				trace.WithSpanKind(trace.SpanKindClient))
+			defer span.End()
+
+			span.SetAttributes(attribute.String("db.operation", qc.Type))
+			span.SetAttributes(attribute.String("db.sql.table", tbl))
+			if qc.Query != nil {
+				span.SetAttributes(attribute.String("db.statement", qc.Query.SQL))
+				span.SetAttributes(attribute.Int("db.args", len(qc.Query.Args)))
+			}
+
+			res := next(ctx, qc)
+			if res.Err != nil {
+				span.RecordError(res.Err)
+				span.SetStatus(codes.Error, res.Err.Error())
+				return res
+			}
+			// 只有 INSERT、UPDATE 和 DELETE 才有影响行数
+			if r, ok := res.Result.(sql.Result); ok {
+				if affected, err := r.RowsAffected(); err == nil {
+					span.SetAttributes(attribute.Int64("db.rows_affected", affected))
+				}
+			}
+			return res
+		}
+	}
+}
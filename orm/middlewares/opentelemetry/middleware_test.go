@@ -0,0 +1,66 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	tracer := tp.Tracer(instrumentationName)
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(MiddlewareBuilder{Tracer: tracer}.Build()))
+	require.NoError(t, err)
+
+	// 模拟 web 那边传过来的 span
+	ctx, parent := tracer.Start(context.Background(), "GET /user")
+
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 2))
+	err = orm.NewUpdater[User](db).Update(&User{Age: 18}).
+		Where(orm.C("Id").Eq(1)).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnError(errors.New("mock error"))
+	_, err = orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).Get(ctx)
+	assert.Equal(t, errors.New("mock error"), err)
+	parent.End()
+
+	spans := sr.Ended()
+	require.Len(t, spans, 3)
+
+	update := spans[0]
+	assert.Equal(t, "UPDATE user", update.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), update.Parent().SpanID())
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("db.operation", "UPDATE"),
+		attribute.String("db.sql.table", "user"),
+		attribute.String("db.statement", "UPDATE `user` SET `age`=? WHERE `id` = ?;"),
+		attribute.Int("db.args", 2),
+		attribute.Int64("db.rows_affected", 2),
+	}, update.Attributes())
+
+	sel := spans[1]
+	assert.Equal(t, "SELECT user", sel.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), sel.Parent().SpanID())
+	assert.Equal(t, codes.Error, sel.Status().Code)
+	assert.Equal(t, "mock error", sel.Status().Description)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type User struct {
+	Id   int64
+	Name string
+	Age  int8
+}
@@ -0,0 +1,103 @@
+package prometheus
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"github.com/prometheus/client_golang/prometheus"
+	"reflect"
+	"time"
+)
+
+type MiddlewareBuilder struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+	// Registerer 默认是 prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+	// Buckets 响应时间的桶，单位是秒，默认是 defaultBuckets
+	Buckets []float64
+}
+
+// defaultBuckets 从 0.5 毫秒到 5 秒，大部分查询都在毫秒级别
+var defaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Build 会注册两个指标：
+// Name_seconds 是响应时间的直方图，单位是秒
+// Name_rows 是查询返回的行数，或者 INSERT、UPDATE、DELETE 影响的行数
+// 标签都是 type（语句类型）、table（表名）和 status（success 或者 error）
+func (m MiddlewareBuilder) Build() orm.Middleware {
+	labels := []string{"type", "table", "status"}
+	buckets := m.Buckets
+	if len(buckets) == 0 {
+		buckets = defaultBuckets
+	}
+	vector := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      m.Name + "_seconds",
+		Subsystem: m.Subsystem,
+		Namespace: m.Namespace,
+		Help:      m.Help,
+		Buckets:   buckets,
+	}, labels)
+	rows := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      m.Name + "_rows",
+		Subsystem: m.Subsystem,
+		Namespace: m.Namespace,
+		Help:      m.Help,
+	}, labels)
+
+	reg := m.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	reg.MustRegister(vector, rows)
+
+	return func(next orm.Handler) orm.Handler {
+		return func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+			startTime := time.Now()
+			res := next(ctx, qc)
+			duration := time.Since(startTime).Seconds()
+
+			table := "unknown"
+			if qc.Model != nil {
+				table = qc.Model.TableName
+			}
+			status := "success"
+			if res.Err != nil {
+				status = "error"
+			}
+			vector.WithLabelValues(qc.Type, table, status).Observe(duration)
+			if cnt, ok := rowsOf(res); ok {
+				rows.WithLabelValues(qc.Type, table, status).Add(float64(cnt))
+			}
+			return res
+		}
+	}
+}
+
+// rowsOf 计算返回的行数
+// Stream 返回的是 *sql.Rows，不知道有多少行，所以不统计
+func rowsOf(res *orm.QueryResult) (int64, bool) {
+	if res.Err != nil || res.Result == nil {
+		return 0, false
+	}
+	switch r := res.Result.(type) {
+	case sql.Result:
+		affected, err := r.RowsAffected()
+		return affected, err == nil
+	case *sql.Rows:
+		return 0, false
+	}
+	val := reflect.ValueOf(res.Result)
+	switch val.Kind() {
+	case reflect.Slice:
+		return int64(val.Len()), true
+	case reflect.Pointer:
+		if val.IsNil() {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	builder := MiddlewareBuilder{
+		Namespace:  "geekbang",
+		Subsystem:  "orm",
+		Name:       "query",
+		Help:       "orm query",
+		Registerer: reg,
+	}
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(builder.Build()))
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"id", "name"})
+	rows.AddRow(1, "Tom")
+	rows.AddRow(2, "Jerry")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	res, err := orm.NewSelector[User](db).GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, res, 2)
+
+	mock.ExpectQuery("SELECT .*").WillReturnError(errors.New("mock error"))
+	_, err = orm.NewSelector[User](db).Get(context.Background())
+	assert.Equal(t, errors.New("mock error"), err)
+
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 3))
+	err = orm.NewDeleter[User](db).Where(orm.C("Id").Gt(1)).Exec(context.Background()).Err()
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// 三个不同的标签组合，每个组合都只有一次查询
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	found := false
+	for _, mf := range mfs {
+		if mf.GetName() != "geekbang_orm_query_seconds" {
+			continue
+		}
+		found = true
+		require.Len(t, mf.GetMetric(), 3)
+		for _, metric := range mf.GetMetric() {
+			h := metric.GetHistogram()
+			assert.Equal(t, uint64(1), h.GetSampleCount())
+			// sqlmock 很快，不到一毫秒，也要能记录下来
+			assert.Greater(t, h.GetSampleSum(), float64(0))
+			assert.Less(t, h.GetSampleSum(), float64(1))
+			assert.Equal(t, defaultBuckets[0], h.GetBucket()[0].GetUpperBound())
+		}
+	}
+	assert.True(t, found)
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP geekbang_orm_query_rows orm query
+# TYPE geekbang_orm_query_rows counter
+geekbang_orm_query_rows{status="success",table="user",type="DELETE"} 3
+geekbang_orm_query_rows{status="success",table="user",type="SELECT"} 2
+`), "geekbang_orm_query_rows")
+	assert.NoError(t, err)
+}
+
+type User struct {
+	Id   int64
+	Name string
+}
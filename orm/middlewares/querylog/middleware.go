@@ -0,0 +1,163 @@
+package querylog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"log"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	ormPkg = "gitee.com/geektime-geekbang/geektime-go/orm."
+	mdlPkg = "gitee.com/geektime-geekbang/geektime-go/orm/middlewares/"
+)
+
+// MiddlewareBuilder 慢查询日志和写操作审计
+type MiddlewareBuilder struct {
+	threshold time.Duration
+	logFunc   func(log string)
+	redact    func(qc *orm.QueryContext, col string, arg any) any
+	audit     func(ctx context.Context, a Audit)
+	skips     []string
+}
+
+// SlowThreshold 超过这个时间的语句才会输出日志，默认是 0，也就是全部输出
+func (m *MiddlewareBuilder) SlowThreshold(d time.Duration) *MiddlewareBuilder {
+	m.threshold = d
+	return m
+}
+
+func (m *MiddlewareBuilder) LogFunc(fn func(log string)) *MiddlewareBuilder {
+	m.logFunc = fn
+	return m
+}
+
+// Redact 用来脱敏，例如把密码、手机号之类的参数替换掉
+// 每个参数都会调用一次，col 是参数绑定的列名，不知道的时候是空字符串
+// 返回值会用于日志和审计，不会影响真正执行的参数
+func (m *MiddlewareBuilder) Redact(fn func(qc *orm.QueryContext, col string, arg any) any) *MiddlewareBuilder {
+	m.redact = fn
+	return m
+}
+
+// CallerSkip 找调用位置的时候，函数名以这些前缀开头的调用栈都会被跳过
+// 默认跳过 orm 和 orm/middlewares 下面的包，
+// 自己封装了一层 DAO 之类的，可以把 DAO 的包也加进来
+func (m *MiddlewareBuilder) CallerSkip(prefixes ...string) *MiddlewareBuilder {
+	m.skips = prefixes
+	return m
+}
+
+// AuditFunc 设置了之后，所有的 INSERT、UPDATE 和 DELETE 都会被记录下来
+func (m *MiddlewareBuilder) AuditFunc(fn func(ctx context.Context, a Audit)) *MiddlewareBuilder {
+	m.audit = fn
+	return m
+}
+
+func (m MiddlewareBuilder) Build() orm.Middleware {
+	if m.logFunc == nil {
+		m.logFunc = func(l string) {
+			log.Println(l)
+		}
+	}
+	if m.skips == nil {
+		m.skips = []string{ormPkg, mdlPkg}
+	}
+	return func(next orm.Handler) orm.Handler {
+		return func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+			startTime := time.Now()
+			res := next(ctx, qc)
+			duration := time.Since(startTime)
+
+			isWrite := qc.Type != "SELECT"
+			if duration < m.threshold && (m.audit == nil || !isWrite) {
+				return res
+			}
+
+			a := Audit{
+				Type:     qc.Type,
+				Caller:   caller(m.skips),
+				Duration: duration,
+			}
+			if qc.Model != nil {
+				a.Table = qc.Model.TableName
+			}
+			if qc.Query != nil {
+				a.SQL = qc.Query.SQL
+				a.Args = qc.Query.Args
+				if m.redact != nil {
+					a.Args = m.redactArgs(qc)
+				}
+			}
+			if res.Err != nil {
+				a.Err = res.Err.Error()
+			} else if r, ok := res.Result.(sql.Result); ok {
+				a.RowsAffected, _ = r.RowsAffected()
+			}
+
+			if duration >= m.threshold {
+				data, _ := json.Marshal(a)
+				m.logFunc(string(data))
+			}
+			if m.audit != nil && isWrite {
+				m.audit(ctx, a)
+			}
+			return res
+		}
+	}
+}
+
+func (m MiddlewareBuilder) redactArgs(qc *orm.QueryContext) []any {
+	res := make([]any, len(qc.Query.Args))
+	for i, arg := range qc.Query.Args {
+		var col string
+		if i < len(qc.ArgColumns) {
+			col = qc.ArgColumns[i]
+		}
+		res[i] = m.redact(qc, col, arg)
+	}
+	return res
+}
+
+// Audit 一条语句的执行记录
+type Audit struct {
+	Type  string `json:"type"`
+	Table string `json:"table,omitempty"`
+	SQL   string `json:"sql"`
+	Args  []any  `json:"args,omitempty"`
+	// 用户代码里面发起查询的位置，file:line
+	Caller       string        `json:"caller,omitempty"`
+	Duration     time.Duration `json:"duration"`
+	RowsAffected int64         `json:"rows_affected,omitempty"`
+	Err          string        `json:"err,omitempty"`
+}
+
+// caller 跳过 orm 和 middleware 自身的调用栈，找到用户代码
+func caller(skips []string) string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !skipped(frame.Function, skips) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func skipped(fn string, skips []string) bool {
+	for _, prefix := range skips {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package querylog
+
+import (
+	"context"
+	"encoding/json"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold time.Duration
+		delay     time.Duration
+		wantLog   bool
+	}{
+		{
+			name:      "slow",
+			threshold: 10 * time.Millisecond,
+			delay:     20 * time.Millisecond,
+			wantLog:   true,
+		},
+		{
+			name:      "fast",
+			threshold: time.Second,
+		},
+		{
+			name:    "no threshold",
+			wantLog: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs []string
+			builder := &MiddlewareBuilder{}
+			// 测试代码本身也在 orm/middlewares 下面，所以只跳过 orm 包
+			mdl := builder.SlowThreshold(tc.threshold).LogFunc(func(log string) {
+				logs = append(logs, log)
+			}).CallerSkip(ormPkg).Build()
+
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(mdl))
+			require.NoError(t, err)
+
+			mock.ExpectQuery("SELECT .*").WillDelayFor(tc.delay).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			_, err = orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).Get(context.Background())
+			require.NoError(t, err)
+
+			if !tc.wantLog {
+				assert.Len(t, logs, 0)
+				return
+			}
+			require.Len(t, logs, 1)
+			var a Audit
+			require.NoError(t, json.Unmarshal([]byte(logs[0]), &a))
+			assert.Equal(t, "SELECT", a.Type)
+			assert.Equal(t, "user", a.Table)
+			assert.Equal(t, "SELECT * FROM `user` WHERE `id` = ?;", a.SQL)
+			assert.Equal(t, []any{float64(1)}, a.Args)
+			assert.True(t, a.Duration >= tc.delay)
+			// 调用位置应该是这个测试文件
+			assert.True(t, strings.Contains(a.Caller, "middleware_test.go"), a.Caller)
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Audit(t *testing.T) {
+	var audits []Audit
+	builder := &MiddlewareBuilder{}
+	mdl := builder.SlowThreshold(time.Hour).
+		Redact(func(qc *orm.QueryContext, col string, arg any) any {
+			if col == "password" {
+				return "***"
+			}
+			return arg
+		}).
+		AuditFunc(func(ctx context.Context, a Audit) {
+			audits = append(audits, a)
+		}).CallerSkip(ormPkg).Build()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(mdl))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, err = orm.NewSelector[User](db).Get(context.Background())
+	require.NoError(t, err)
+
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 2))
+	err = orm.NewUpdater[User](db).Update(&User{Password: "123456"}).
+		Where(orm.C("Id").Eq(1)).Exec(context.Background()).Err()
+	require.NoError(t, err)
+
+	// 查询不会被审计
+	require.Len(t, audits, 1)
+	a := audits[0]
+	assert.Equal(t, "UPDATE", a.Type)
+	assert.Equal(t, "UPDATE `user` SET `password`=? WHERE `id` = ?;", a.SQL)
+	assert.Equal(t, []any{"***", 1}, a.Args)
+	assert.Equal(t, int64(2), a.RowsAffected)
+	assert.True(t, strings.Contains(a.Caller, "middleware_test.go"), a.Caller)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type User struct {
+	Id       int64
+	Password string
+}
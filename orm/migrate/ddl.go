@@ -0,0 +1,166 @@
+package migrate
+
+import (
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"strings"
+)
+
+// createTable 生成建表语句，索引是单独的 CREATE INDEX 语句
+func createTable(d Dialect, m *model.Model) ([]string, error) {
+	pks := m.PrimaryKeys()
+	var sb strings.Builder
+	sb.WriteString("CREATE TABLE ")
+	sb.WriteString(d.quote(m.TableName))
+	sb.WriteString(" (")
+	inlinePK := false
+	for i, fd := range m.Fields {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		def, inline, err := columnDef(d, fd, len(pks) == 1)
+		if err != nil {
+			return nil, err
+		}
+		inlinePK = inlinePK || inline
+		sb.WriteString(def)
+	}
+	if len(pks) > 0 && !inlinePK {
+		sb.WriteString(", PRIMARY KEY (")
+		for i, pk := range pks {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(d.quote(pk.ColName))
+		}
+		sb.WriteByte(')')
+	}
+	sb.WriteString(");")
+	return append([]string{sb.String()}, createIndexes(d, m)...), nil
+}
+
+// columnDef 构造完整的列定义，singlePK 代表这个表只有一个主键
+// 返回的 bool 代表主键是不是已经写在了列定义里面
+func columnDef(d Dialect, fd *model.Field, singlePK bool) (string, bool, error) {
+	typ, err := columnType(d, fd)
+	if err != nil {
+		return "", false, err
+	}
+	suffix, inline := "", false
+	if fd.AutoIncrement {
+		typ, suffix, inline = d.autoIncrement(typ)
+		if inline && !(fd.PrimaryKey && singlePK) {
+			return "", false, errs.NewErrUnsupportedDialectFeature(d.name(), "非单一主键的自增列")
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString(d.quote(fd.ColName))
+	sb.WriteByte(' ')
+	sb.WriteString(typ)
+	if !nullable(fd) {
+		sb.WriteString(" NOT NULL")
+	}
+	if fd.Default != "" && !fd.AutoIncrement {
+		sb.WriteString(" DEFAULT ")
+		sb.WriteString(fd.Default)
+	}
+	sb.WriteString(suffix)
+	return sb.String(), inline, nil
+}
+
+// addColumnDef 构造 ADD COLUMN 的列定义，不处理主键和自增。
+// 已经有数据的表加 NOT NULL 的列必须要有默认值，SQLite 更是强制要求，
+// 所以没有设置默认值的时候会用方言的零值，没有合适的零值就返回错误
+func addColumnDef(d Dialect, fd *model.Field) (string, error) {
+	typ, err := columnType(d, fd)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(d.quote(fd.ColName))
+	sb.WriteByte(' ')
+	sb.WriteString(typ)
+	def := fd.Default
+	if !nullable(fd) {
+		sb.WriteString(" NOT NULL")
+		if def == "" {
+			var ok bool
+			def, ok = d.zeroDefault(logicalTypeOf(fd), fd.Size)
+			if !ok {
+				return "", errs.NewErrNoZeroDefault(fd.GoName)
+			}
+		}
+	}
+	if def != "" {
+		sb.WriteString(" DEFAULT ")
+		sb.WriteString(def)
+	}
+	return sb.String(), nil
+}
+
+func columnType(d Dialect, fd *model.Field) (string, error) {
+	if fd.SQLType != "" {
+		return fd.SQLType, nil
+	}
+	typ := d.sqlType(logicalTypeOf(fd), fd.Size)
+	if typ == "" {
+		return "", errs.NewErrUnsupportedColumnType(fd.GoName, fd.Type.String())
+	}
+	return typ, nil
+}
+
+// zeroDefault 数值、bool 和字符串的零值，各个数据库的写法是一样的，
+// 时间和字节串要看方言，返回 false 代表没有合适的常量
+func zeroDefault(lt logicalType) (string, bool) {
+	switch lt {
+	case typeBool:
+		return "FALSE", true
+	case typeString:
+		return "''", true
+	case typeTime, typeBytes, typeUnknown:
+		return "", false
+	}
+	return "0", true
+}
+
+// createIndexes 同名的索引就是联合索引，列的顺序和字段定义的顺序一致
+func createIndexes(d Dialect, m *model.Model) []string {
+	var res []string
+	build := func(name string, unique bool, getName func(fd *model.Field) string) {
+		var sb strings.Builder
+		sb.WriteString("CREATE ")
+		if unique {
+			sb.WriteString("UNIQUE ")
+		}
+		sb.WriteString("INDEX ")
+		sb.WriteString(d.quote(name))
+		sb.WriteString(" ON ")
+		sb.WriteString(d.quote(m.TableName))
+		sb.WriteString(" (")
+		cnt := 0
+		for _, fd := range m.Fields {
+			if getName(fd) != name {
+				continue
+			}
+			if cnt > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(d.quote(fd.ColName))
+			cnt++
+		}
+		sb.WriteString(");")
+		res = append(res, sb.String())
+	}
+	seen := make(map[string]struct{}, 4)
+	for _, fd := range m.Fields {
+		if _, ok := seen[fd.Index]; fd.Index != "" && !ok {
+			seen[fd.Index] = struct{}{}
+			build(fd.Index, false, func(fd *model.Field) string { return fd.Index })
+		}
+		if _, ok := seen[fd.Unique]; fd.Unique != "" && !ok {
+			seen[fd.Unique] = struct{}{}
+			build(fd.Unique, true, func(fd *model.Field) string { return fd.Unique })
+		}
+	}
+	return res
+}
@@ -0,0 +1,175 @@
+package migrate
+
+import (
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCreateTable(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		entity  any
+
+		wantSQLs []string
+		wantErr  error
+	}{
+		{
+			name:    "mysql",
+			dialect: DialectMySQL,
+			entity:  &User{},
+			wantSQLs: []string{
+				"CREATE TABLE `user` (`id` BIGINT NOT NULL AUTO_INCREMENT, `email` VARCHAR(128) NOT NULL, " +
+					"`nick_name` VARCHAR(255), `age` TINYINT UNSIGNED NOT NULL DEFAULT 18, " +
+					"`balance` DECIMAL(10,2) NOT NULL, `active` TINYINT(1) NOT NULL, `avatar` BLOB NOT NULL, " +
+					"`remark` VARCHAR(255), `created_at` DATETIME NOT NULL, PRIMARY KEY (`id`));",
+				"CREATE UNIQUE INDEX `uk_user_email` ON `user` (`email`);",
+				"CREATE INDEX `idx_age_created_at` ON `user` (`age`, `created_at`);",
+			},
+		},
+		{
+			name:    "sqlite",
+			dialect: DialectSQLite,
+			entity:  &User{},
+			wantSQLs: []string{
+				"CREATE TABLE `user` (`id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, `email` TEXT NOT NULL, " +
+					"`nick_name` TEXT, `age` INTEGER NOT NULL DEFAULT 18, " +
+					"`balance` DECIMAL(10,2) NOT NULL, `active` INTEGER NOT NULL, `avatar` BLOB NOT NULL, " +
+					"`remark` TEXT, `created_at` DATETIME NOT NULL);",
+				"CREATE UNIQUE INDEX `uk_user_email` ON `user` (`email`);",
+				"CREATE INDEX `idx_age_created_at` ON `user` (`age`, `created_at`);",
+			},
+		},
+		{
+			name:    "postgresql",
+			dialect: DialectPostgreSQL,
+			entity:  &User{},
+			wantSQLs: []string{
+				`CREATE TABLE "user" ("id" BIGSERIAL NOT NULL, "email" VARCHAR(128) NOT NULL, ` +
+					`"nick_name" VARCHAR(255), "age" SMALLINT NOT NULL DEFAULT 18, ` +
+					`"balance" DECIMAL(10,2) NOT NULL, "active" BOOLEAN NOT NULL, "avatar" BYTEA NOT NULL, ` +
+					`"remark" VARCHAR(255), "created_at" TIMESTAMP NOT NULL, PRIMARY KEY ("id"));`,
+				`CREATE UNIQUE INDEX "uk_user_email" ON "user" ("email");`,
+				`CREATE INDEX "idx_age_created_at" ON "user" ("age", "created_at");`,
+			},
+		},
+		{
+			name:    "composite primary key",
+			dialect: DialectPostgreSQL,
+			entity: func() any {
+				type UserRole struct {
+					UserId int64 `orm:"pk"`
+					RoleId int32 `orm:"pk"`
+				}
+				return &UserRole{}
+			}(),
+			wantSQLs: []string{
+				`CREATE TABLE "user_role" ("user_id" BIGINT NOT NULL, "role_id" INTEGER NOT NULL, ` +
+					`PRIMARY KEY ("user_id", "role_id"));`,
+			},
+		},
+		{
+			name:    "unsupported type",
+			dialect: DialectMySQL,
+			entity: func() any {
+				type Order struct {
+					Items []string
+				}
+				return &Order{}
+			}(),
+			wantErr: errs.NewErrUnsupportedColumnType("Items", "[]string"),
+		},
+		{
+			name:    "sqlite auto increment without pk",
+			dialect: DialectSQLite,
+			entity: func() any {
+				type Order struct {
+					Seq int64 `orm:"auto_increment"`
+				}
+				return &Order{}
+			}(),
+			wantErr: errs.NewErrUnsupportedDialectFeature("SQLite", "非单一主键的自增列"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := model.NewRegistry().Get(tc.entity)
+			require.NoError(t, err)
+			sqls, err := createTable(tc.dialect, m)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantSQLs, sqls)
+		})
+	}
+}
+
+func TestAddColumnDef(t *testing.T) {
+	m, err := model.NewRegistry().Get(&User{})
+	require.NoError(t, err)
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		field   string
+
+		wantDef string
+	}{
+		{
+			name:    "mysql time",
+			dialect: DialectMySQL,
+			field:   "CreatedAt",
+			wantDef: "`created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP",
+		},
+		{
+			// BLOB 不能有默认值，MySQL 会用空字节串
+			name:    "mysql blob",
+			dialect: DialectMySQL,
+			field:   "Avatar",
+			wantDef: "`avatar` BLOB NOT NULL",
+		},
+		{
+			name:    "sqlite time",
+			dialect: DialectSQLite,
+			field:   "CreatedAt",
+			wantDef: "`created_at` DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00'",
+		},
+		{
+			name:    "postgresql time",
+			dialect: DialectPostgreSQL,
+			field:   "CreatedAt",
+			wantDef: `"created_at" TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+		},
+		{
+			name:    "postgresql bytes",
+			dialect: DialectPostgreSQL,
+			field:   "Avatar",
+			wantDef: `"avatar" BYTEA NOT NULL DEFAULT ''::bytea`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			def, err := addColumnDef(tc.dialect, m.FieldMap[tc.field])
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantDef, def)
+		})
+	}
+}
+
+type User struct {
+	Id        int64  `orm:"pk,auto_increment"`
+	Email     string `orm:"size=128,unique"`
+	NickName  *string
+	Age       uint8   `orm:"default=18,index=idx_age_created_at"`
+	Balance   float64 `orm:"type=DECIMAL(10,2)"`
+	Active    bool
+	Avatar    []byte
+	Remark    sql.NullString
+	CreatedAt time.Time `orm:"index=idx_age_created_at"`
+}
@@ -0,0 +1,334 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	DialectMySQL      Dialect = mysqlDialect{ormDialect{orm.DialectMySQL}}
+	DialectSQLite     Dialect = sqliteDialect{ormDialect{orm.DialectSQLite}}
+	DialectPostgreSQL Dialect = postgreDialect{ormDialect{orm.DialectPostgreSQL}}
+)
+
+// Dialect 和 orm.Dialect 类似，只不过关心的是 DDL
+type Dialect interface {
+	// name 用在错误信息里面
+	name() string
+	quote(name string) string
+	// rebind 把 ? 占位符改写成方言自己的占位符
+	rebind(query string) string
+	// sqlType 返回列的类型，size 为 0 代表没有设置
+	sqlType(lt logicalType, size int) string
+	// autoIncrement 把 typ 改造成自增列，
+	// 返回新的类型、跟在列定义后面的修饰，以及主键是否要写在列定义里面
+	autoIncrement(typ string) (string, string, bool)
+	// zeroDefault 往已有的表里面加 NOT NULL 的列的时候用的默认值，
+	// 返回空字符串代表不需要 DEFAULT，false 代表没有合适的常量
+	zeroDefault(lt logicalType, size int) (string, bool)
+	// normalizeType 把类型转成统一的写法，Diff 用它比较已有的列的类型有没有变化
+	normalizeType(typ string) string
+	// columns 返回表里面已有的列，表不存在的时候返回空切片
+	columns(ctx context.Context, db *sql.DB, table string) ([]Column, error)
+	dropColumn(table string, col string) (string, error)
+}
+
+// ormDialect 引号和占位符直接用 orm 的方言
+type ormDialect struct {
+	d orm.Dialect
+}
+
+func (o ormDialect) quote(name string) string {
+	return orm.Quote(o.d, name)
+}
+
+func (o ormDialect) rebind(query string) string {
+	return orm.Rebind(o.d, query)
+}
+
+func (o ormDialect) dropColumn(table string, col string) (string, error) {
+	return "ALTER TABLE " + o.quote(table) + " DROP COLUMN " + o.quote(col) + ";", nil
+}
+
+type mysqlDialect struct {
+	ormDialect
+}
+
+func (d mysqlDialect) name() string {
+	return "MySQL"
+}
+
+func (d mysqlDialect) sqlType(lt logicalType, size int) string {
+	switch lt {
+	case typeBool:
+		return "TINYINT(1)"
+	case typeInt8:
+		return "TINYINT"
+	case typeInt16:
+		return "SMALLINT"
+	case typeInt32:
+		return "INT"
+	case typeInt64:
+		return "BIGINT"
+	case typeUint8:
+		return "TINYINT UNSIGNED"
+	case typeUint16:
+		return "SMALLINT UNSIGNED"
+	case typeUint32:
+		return "INT UNSIGNED"
+	case typeUint64:
+		return "BIGINT UNSIGNED"
+	case typeFloat32:
+		return "FLOAT"
+	case typeFloat64:
+		return "DOUBLE"
+	case typeString:
+		return "VARCHAR(" + sizeOr(size, 255) + ")"
+	case typeBytes:
+		if size > 0 {
+			return "VARBINARY(" + strconv.Itoa(size) + ")"
+		}
+		return "BLOB"
+	case typeTime:
+		return "DATETIME"
+	}
+	return ""
+}
+
+func (d mysqlDialect) autoIncrement(typ string) (string, string, bool) {
+	return typ, " AUTO_INCREMENT", false
+}
+
+// zeroDefault BLOB 不能有默认值，加列的时候 MySQL 会用隐式的默认值，也就是空字节串
+func (d mysqlDialect) zeroDefault(lt logicalType, size int) (string, bool) {
+	switch lt {
+	case typeTime:
+		return "CURRENT_TIMESTAMP", true
+	case typeBytes:
+		if size > 0 {
+			return "''", true
+		}
+		return "", true
+	}
+	return zeroDefault(lt)
+}
+
+// mysqlIntWidth 5.7 的 column_type 会带上整数的显示宽度，例如 bigint(20)
+var mysqlIntWidth = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\((\d+)\)`)
+
+// normalizeType 去掉整数的显示宽度，只有 tinyint(1) 是 bool，要保留
+func (d mysqlDialect) normalizeType(typ string) string {
+	typ = strings.ToLower(strings.Join(strings.Fields(typ), " "))
+	return mysqlIntWidth.ReplaceAllStringFunc(typ, func(s string) string {
+		if s == "tinyint(1)" {
+			return s
+		}
+		return s[:strings.IndexByte(s, '(')]
+	})
+}
+
+func (d mysqlDialect) columns(ctx context.Context, db *sql.DB, table string) ([]Column, error) {
+	return queryColumns(ctx, db, "SELECT `column_name`, `column_type`, `is_nullable`, `column_default` "+
+		"FROM `information_schema`.`columns` WHERE `table_schema` = DATABASE() AND `table_name` = ? "+
+		"ORDER BY `ordinal_position`", table)
+}
+
+type sqliteDialect struct {
+	ormDialect
+}
+
+func (d sqliteDialect) name() string {
+	return "SQLite"
+}
+
+// sqlType SQLite 只有几种存储类型，长度也是没用的
+func (d sqliteDialect) sqlType(lt logicalType, size int) string {
+	switch lt {
+	case typeBool, typeInt8, typeInt16, typeInt32, typeInt64,
+		typeUint8, typeUint16, typeUint32, typeUint64:
+		return "INTEGER"
+	case typeFloat32, typeFloat64:
+		return "REAL"
+	case typeString:
+		return "TEXT"
+	case typeBytes:
+		return "BLOB"
+	case typeTime:
+		return "DATETIME"
+	}
+	return ""
+}
+
+// autoIncrement SQLite 只有 INTEGER PRIMARY KEY 才能自增
+func (d sqliteDialect) autoIncrement(typ string) (string, string, bool) {
+	return "INTEGER", " PRIMARY KEY AUTOINCREMENT", true
+}
+
+// zeroDefault SQLite 加列的时候默认值必须是常量，所以不能用 CURRENT_TIMESTAMP
+func (d sqliteDialect) zeroDefault(lt logicalType, size int) (string, bool) {
+	switch lt {
+	case typeTime:
+		return "'1970-01-01 00:00:00'", true
+	case typeBytes:
+		return "X''", true
+	}
+	return zeroDefault(lt)
+}
+
+// normalizeType PRAGMA table_info 返回的就是建表的时候写的类型
+func (d sqliteDialect) normalizeType(typ string) string {
+	return strings.ToLower(strings.Join(strings.Fields(typ), " "))
+}
+
+func (d sqliteDialect) columns(ctx context.Context, db *sql.DB, table string) ([]Column, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA table_info("+d.quote(table)+")")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []Column
+	for rows.Next() {
+		var (
+			cid     int
+			col     Column
+			notNull bool
+			pk      int
+		)
+		if err = rows.Scan(&cid, &col.Name, &col.Type, &notNull, &col.Default, &pk); err != nil {
+			return nil, err
+		}
+		// INTEGER PRIMARY KEY 就是 rowid，即便没有声明 NOT NULL 也不可能是 NULL
+		col.Nullable = !notNull && pk == 0
+		res = append(res, col)
+	}
+	return res, rows.Err()
+}
+
+func (d sqliteDialect) dropColumn(table string, col string) (string, error) {
+	// 3.35 之前的 SQLite 不支持，只能重建表
+	return "", errs.NewErrUnsupportedDialectFeature(d.name(), "DROP COLUMN")
+}
+
+type postgreDialect struct {
+	ormDialect
+}
+
+func (d postgreDialect) name() string {
+	return "PostgreSQL"
+}
+
+func (d postgreDialect) sqlType(lt logicalType, size int) string {
+	switch lt {
+	case typeBool:
+		return "BOOLEAN"
+	case typeInt8, typeInt16, typeUint8:
+		return "SMALLINT"
+	case typeInt32, typeUint16:
+		return "INTEGER"
+	case typeInt64, typeUint32:
+		return "BIGINT"
+	case typeUint64:
+		return "NUMERIC(20)"
+	case typeFloat32:
+		return "REAL"
+	case typeFloat64:
+		return "DOUBLE PRECISION"
+	case typeString:
+		return "VARCHAR(" + sizeOr(size, 255) + ")"
+	case typeBytes:
+		return "BYTEA"
+	case typeTime:
+		return "TIMESTAMP"
+	}
+	return ""
+}
+
+// autoIncrement PostgreSQL 用 SERIAL 系列的类型
+func (d postgreDialect) autoIncrement(typ string) (string, string, bool) {
+	switch typ {
+	case "SMALLINT":
+		return "SMALLSERIAL", "", false
+	case "INTEGER":
+		return "SERIAL", "", false
+	}
+	return "BIGSERIAL", "", false
+}
+
+// postgreTypes format_type 返回的是类型的全称，SERIAL 系列建表之后就是对应的整数
+var postgreTypes = strings.NewReplacer(
+	"character varying", "varchar",
+	"timestamp without time zone", "timestamp",
+	",0)", ")",
+)
+
+func (d postgreDialect) zeroDefault(lt logicalType, size int) (string, bool) {
+	switch lt {
+	case typeTime:
+		return "'1970-01-01 00:00:00'", true
+	case typeBytes:
+		return "''::bytea", true
+	}
+	return zeroDefault(lt)
+}
+
+func (d postgreDialect) normalizeType(typ string) string {
+	typ = postgreTypes.Replace(strings.ToLower(strings.Join(strings.Fields(typ), " ")))
+	switch typ {
+	case "smallserial":
+		return "smallint"
+	case "serial":
+		return "integer"
+	case "bigserial":
+		return "bigint"
+	}
+	return typ
+}
+
+// columns information_schema 里面的 data_type 不带长度，所以类型用 format_type 来拿
+func (d postgreDialect) columns(ctx context.Context, db *sql.DB, table string) ([]Column, error) {
+	return queryColumns(ctx, db, `SELECT "a"."attname", format_type("a"."atttypid", "a"."atttypmod"), `+
+		`CASE WHEN "a"."attnotnull" THEN 'NO' ELSE 'YES' END, pg_get_expr("d"."adbin", "d"."adrelid") `+
+		`FROM "pg_catalog"."pg_attribute" AS "a" `+
+		`JOIN "pg_catalog"."pg_class" AS "c" ON "c"."oid" = "a"."attrelid" `+
+		`LEFT JOIN "pg_catalog"."pg_attrdef" AS "d" ON "d"."adrelid" = "a"."attrelid" AND "d"."adnum" = "a"."attnum" `+
+		`WHERE "c"."relnamespace" = current_schema()::regnamespace AND "c"."relname" = $1 `+
+		`AND "a"."attnum" > 0 AND NOT "a"."attisdropped" ORDER BY "a"."attnum"`, table)
+}
+
+// queryColumns 结果是列名、类型、YES/NO 和默认值
+func queryColumns(ctx context.Context, db *sql.DB, query string, table string) ([]Column, error) {
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []Column
+	for rows.Next() {
+		var (
+			col        Column
+			isNullable string
+		)
+		if err = rows.Scan(&col.Name, &col.Type, &isNullable, &col.Default); err != nil {
+			return nil, err
+		}
+		col.Nullable = strings.EqualFold(isNullable, "YES")
+		res = append(res, col)
+	}
+	return res, rows.Err()
+}
+
+func sizeOr(size int, def int) string {
+	if size > 0 {
+		return strconv.Itoa(size)
+	}
+	return strconv.Itoa(def)
+}
@@ -0,0 +1,108 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"sort"
+	"time"
+)
+
+// Migration 是一个版本的变更
+type Migration struct {
+	// Version 要求唯一，按照从小到大的顺序执行，一般用时间，例如 202210161200
+	Version int64
+	Name    string
+	// Up 在事务里面执行，
+	// 要注意 MySQL 的 DDL 语句会隐式提交事务，所以一个 Migration 最好只有一条 DDL
+	Up func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Migrate 执行还没有执行过的 migrations，每个 Migration 一个事务，
+// 执行成功之后会在版本表里面记录一下，下次就不会再执行
+func (m *Migrator) Migrate(ctx context.Context, migrations ...Migration) error {
+	if err := m.createVersionTable(ctx); err != nil {
+		return err
+	}
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return err
+	}
+	done := make(map[int64]struct{}, len(applied))
+	for _, v := range applied {
+		done[v] = struct{}{}
+	}
+
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	for i, mig := range sorted {
+		if i > 0 && sorted[i-1].Version == mig.Version {
+			return errs.NewErrDuplicateMigration(mig.Version)
+		}
+	}
+
+	for _, mig := range sorted {
+		if _, ok := done[mig.Version]; ok {
+			continue
+		}
+		if err = m.apply(ctx, mig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Applied 返回已经执行过的版本，从小到大
+func (m *Migrator) Applied(ctx context.Context) ([]int64, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+m.dialect.quote("version")+
+		" FROM "+m.dialect.quote(m.table)+" ORDER BY "+m.dialect.quote("version")+";")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []int64
+	for rows.Next() {
+		var v int64
+		if err = rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = mig.Up(ctx, tx)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, m.dialect.rebind("INSERT INTO "+m.dialect.quote(m.table)+"("+
+			m.dialect.quote("version")+", "+m.dialect.quote("name")+", "+m.dialect.quote("applied_at")+
+			") VALUES (?, ?, ?);"),
+			mig.Version, mig.Name, time.Now().UnixMilli())
+	}
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errs.NewErrFailToRollbackTx(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) createVersionTable(ctx context.Context) error {
+	d := m.dialect
+	_, err := m.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+d.quote(m.table)+" ("+
+		d.quote("version")+" BIGINT NOT NULL, "+
+		d.quote("name")+" "+d.sqlType(typeString, 255)+" NOT NULL, "+
+		d.quote("applied_at")+" BIGINT NOT NULL, "+
+		"PRIMARY KEY ("+d.quote("version")+"));")
+	return err
+}
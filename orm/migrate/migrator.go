@@ -0,0 +1,208 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"strings"
+)
+
+type Option func(m *Migrator)
+
+// Migrator 根据 model.Registry 里面的元数据维护表结构
+type Migrator struct {
+	db      *sql.DB
+	dialect Dialect
+	r       model.Registry
+	// table 是记录已经执行过的版本的表
+	table     string
+	allowDrop bool
+}
+
+func NewMigrator(db *sql.DB, dialect Dialect, opts ...Option) *Migrator {
+	res := &Migrator{
+		db:      db,
+		dialect: dialect,
+		r:       model.NewRegistry(),
+		table:   "orm_migrations",
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// MigratorWithRegistry 要和 orm.DBWithRegistry 用同一个 Registry，
+// 不然通过 Register 设置的 Option 不会生效
+func MigratorWithRegistry(r model.Registry) Option {
+	return func(m *Migrator) {
+		m.r = r
+	}
+}
+
+// MigratorWithTableName 修改记录版本的表名，默认是 orm_migrations
+func MigratorWithTableName(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// MigratorAllowDropColumn 允许 Diff 删除模型里面没有的列，默认是不删除的
+func MigratorAllowDropColumn() Option {
+	return func(m *Migrator) {
+		m.allowDrop = true
+	}
+}
+
+// CreateTable 生成 entity 的建表语句，不会执行
+func (m *Migrator) CreateTable(entity any) ([]string, error) {
+	md, err := m.r.Get(entity)
+	if err != nil {
+		return nil, err
+	}
+	return createTable(m.dialect, md)
+}
+
+// Diff 比较 entity 和数据库里面的表，返回需要执行的语句。
+// 表不存在就是建表语句，否则是 ALTER TABLE 语句，
+// 目前只会增加（删除）列，也不会处理索引。
+// 已有的列的类型、是否允许 NULL 或者默认值变了，会返回错误，需要自己写 Migration
+func (m *Migrator) Diff(ctx context.Context, entity any) ([]string, error) {
+	md, err := m.r.Get(entity)
+	if err != nil {
+		return nil, err
+	}
+	cols, err := m.dialect.columns(ctx, m.db, md.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return createTable(m.dialect, md)
+	}
+
+	existing := make(map[string]Column, len(cols))
+	for _, col := range cols {
+		existing[col.Name] = col
+	}
+	var res []string
+	for _, fd := range md.Fields {
+		if col, ok := existing[fd.ColName]; ok {
+			if err = m.checkColumn(md.TableName, fd, col); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		def, err := addColumnDef(m.dialect, fd)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, "ALTER TABLE "+m.dialect.quote(md.TableName)+" ADD COLUMN "+def+";")
+	}
+	if !m.allowDrop {
+		return res, nil
+	}
+	for _, col := range cols {
+		if _, ok := md.ColumnMap[col.Name]; ok {
+			continue
+		}
+		stmt, err := m.dialect.dropColumn(md.TableName, col.Name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, stmt)
+	}
+	return res, nil
+}
+
+// checkColumn 检查已有的列和字段的定义是否一致
+func (m *Migrator) checkColumn(table string, fd *model.Field, col Column) error {
+	typ, err := columnType(m.dialect, fd)
+	if err != nil {
+		return err
+	}
+	if fd.AutoIncrement {
+		typ, _, _ = m.dialect.autoIncrement(typ)
+	}
+	if m.dialect.normalizeType(typ) != m.dialect.normalizeType(col.Type) {
+		return errs.NewErrColumnChanged(table, col.Name, "类型", col.Type, typ)
+	}
+	if null := nullable(fd); null != col.Nullable {
+		return errs.NewErrColumnChanged(table, col.Name, "NULL 约束", nullText(col.Nullable), nullText(null))
+	}
+	// 自增列的默认值是数据库自己维护的，例如 PostgreSQL 的 nextval
+	if fd.AutoIncrement || sameDefault(m.dialect, fd, col.Default) {
+		return nil
+	}
+	from, to := "无", "无"
+	if col.Default.Valid {
+		from = col.Default.String
+	}
+	if fd.Default != "" {
+		to = fd.Default
+	}
+	return errs.NewErrColumnChanged(table, col.Name, "默认值", from, to)
+}
+
+func nullText(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// sameDefault 没有设置默认值的字段，ADD COLUMN 的时候可能会用零值作为默认值，所以两种都算一样
+func sameDefault(d Dialect, fd *model.Field, dflt sql.NullString) bool {
+	want := fd.Default
+	if want == "" {
+		if !dflt.Valid {
+			return true
+		}
+		if nullable(fd) {
+			return false
+		}
+		want, _ = d.zeroDefault(logicalTypeOf(fd), fd.Size)
+	}
+	if !dflt.Valid {
+		return false
+	}
+	return strings.EqualFold(normalizeDefault(want), normalizeDefault(dflt.String))
+}
+
+// normalizeDefault 各个数据库返回的默认值的写法不一样，
+// 例如 MySQL 的字符串不带引号，PostgreSQL 会带上类型转换 'new'::character varying
+func normalizeDefault(dflt string) string {
+	dflt = strings.TrimSpace(dflt)
+	if strings.HasPrefix(dflt, "'") {
+		if end := strings.LastIndexByte(dflt, '\''); end > 0 {
+			return strings.ReplaceAll(dflt[1:end], "''", "'")
+		}
+	}
+	if idx := strings.Index(dflt, "::"); idx > 0 {
+		dflt = dflt[:idx]
+	}
+	dflt = strings.TrimSuffix(strings.Trim(dflt, "()"), "()")
+	switch strings.ToUpper(dflt) {
+	case "TRUE":
+		return "1"
+	case "FALSE":
+		return "0"
+	}
+	return dflt
+}
+
+// AutoMigrate 依次对 entities 执行 Diff 得到的语句
+func (m *Migrator) AutoMigrate(ctx context.Context, entities ...any) error {
+	for _, entity := range entities {
+		stmts, err := m.Diff(ctx, entity)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range stmts {
+			if _, err = m.db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
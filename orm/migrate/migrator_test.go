@@ -0,0 +1,315 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMigrator_AutoMigrate(t *testing.T) {
+	db := memoryDB(t, "auto_migrate")
+	m := NewMigrator(db, DialectSQLite)
+	ctx := context.Background()
+
+	// 表不存在，建表
+	sqls, err := m.Diff(ctx, &OrderV1{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE `order` (`id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, `buyer` TEXT NOT NULL);",
+	}, sqls)
+	require.NoError(t, m.AutoMigrate(ctx, &OrderV1{}))
+	_, err = db.Exec("INSERT INTO `order`(`buyer`) VALUES ('Tom')")
+	require.NoError(t, err)
+
+	// 表结构一致
+	sqls, err = m.Diff(ctx, &OrderV1{})
+	require.NoError(t, err)
+	assert.Len(t, sqls, 0)
+
+	// 增加了列
+	sqls, err = m.Diff(ctx, &OrderV2{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE `order` ADD COLUMN `amount` INTEGER NOT NULL DEFAULT 0;",
+		"ALTER TABLE `order` ADD COLUMN `remark` TEXT;",
+		"ALTER TABLE `order` ADD COLUMN `status` TEXT NOT NULL DEFAULT 'new';",
+	}, sqls)
+	require.NoError(t, m.AutoMigrate(ctx, &OrderV2{}))
+	var (
+		amount int64
+		remark sql.NullString
+		status string
+	)
+	err = db.QueryRow("SELECT `amount`, `remark`, `status` FROM `order` WHERE `buyer` = 'Tom'").
+		Scan(&amount, &remark, &status)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), amount)
+	assert.False(t, remark.Valid)
+	assert.Equal(t, "new", status)
+
+	cols, err := DialectSQLite.columns(ctx, db, "order")
+	require.NoError(t, err)
+	assert.Equal(t, []Column{
+		{Name: "id", Type: "INTEGER"},
+		{Name: "buyer", Type: "TEXT"},
+		{Name: "amount", Type: "INTEGER", Default: sql.NullString{String: "0", Valid: true}},
+		{Name: "remark", Type: "TEXT", Nullable: true},
+		{Name: "status", Type: "TEXT", Default: sql.NullString{String: "'new'", Valid: true}},
+	}, cols)
+
+	// ADD COLUMN 用零值作为默认值，依旧认为是一致的
+	sqls, err = m.Diff(ctx, &OrderV2{})
+	require.NoError(t, err)
+	assert.Len(t, sqls, 0)
+
+	// 已有的列变了，不会自动修改
+	_, err = m.Diff(ctx, &OrderType{})
+	assert.Equal(t, errs.NewErrColumnChanged("order", "amount", "类型", "INTEGER", "REAL"), err)
+	_, err = m.Diff(ctx, &OrderNull{})
+	assert.Equal(t, errs.NewErrColumnChanged("order", "remark", "NULL 约束", "NULL", "NOT NULL"), err)
+	_, err = m.Diff(ctx, &OrderDefault{})
+	assert.Equal(t, errs.NewErrColumnChanged("order", "status", "默认值", "'new'", "'paid'"), err)
+
+	// 已经有数据的表加时间和字节串的列，SQLite 要求默认值是常量
+	sqls, err = m.Diff(ctx, &OrderV3{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE `order` ADD COLUMN `created_at` DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';",
+		"ALTER TABLE `order` ADD COLUMN `data` BLOB NOT NULL DEFAULT X'';",
+	}, sqls)
+	require.NoError(t, m.AutoMigrate(ctx, &OrderV3{}))
+	var (
+		createdAt time.Time
+		data      []byte
+	)
+	err = db.QueryRow("SELECT `created_at`, `data` FROM `order` WHERE `buyer` = 'Tom'").
+		Scan(&createdAt, &data)
+	require.NoError(t, err)
+	assert.True(t, createdAt.Equal(time.Unix(0, 0)), createdAt)
+	assert.Equal(t, []byte{}, data)
+	sqls, err = m.Diff(ctx, &OrderV3{})
+	require.NoError(t, err)
+	assert.Len(t, sqls, 0)
+
+	// 没办法知道零值的类型，要求通过 default 标签指定
+	_, err = m.Diff(ctx, &OrderUnknown{})
+	assert.Equal(t, errs.NewErrNoZeroDefault("Extra"), err)
+
+	// 少了列，默认不删除
+	sqls, err = m.Diff(ctx, &OrderV1{})
+	require.NoError(t, err)
+	assert.Len(t, sqls, 0)
+	_, err = NewMigrator(db, DialectSQLite, MigratorAllowDropColumn()).Diff(ctx, &OrderV1{})
+	assert.Equal(t, errs.NewErrUnsupportedDialectFeature("SQLite", "DROP COLUMN"), err)
+}
+
+func TestDialect_normalizeType(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		want    string
+		got     string
+	}{
+		{
+			// 5.7 会带上显示宽度
+			name:    "mysql int width",
+			dialect: DialectMySQL,
+			want:    "BIGINT UNSIGNED",
+			got:     "bigint(20) unsigned",
+		},
+		{
+			name:    "mysql bool",
+			dialect: DialectMySQL,
+			want:    "TINYINT(1)",
+			got:     "tinyint(1)",
+		},
+		{
+			name:    "postgresql varchar",
+			dialect: DialectPostgreSQL,
+			want:    "VARCHAR(255)",
+			got:     "character varying(255)",
+		},
+		{
+			name:    "postgresql serial",
+			dialect: DialectPostgreSQL,
+			want:    "BIGSERIAL",
+			got:     "bigint",
+		},
+		{
+			name:    "postgresql numeric",
+			dialect: DialectPostgreSQL,
+			want:    "NUMERIC(20)",
+			got:     "numeric(20,0)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.dialect.normalizeType(tc.want), tc.dialect.normalizeType(tc.got))
+		})
+	}
+}
+
+func TestNormalizeDefault(t *testing.T) {
+	testCases := []struct {
+		dflt string
+		want string
+	}{
+		{dflt: "'new'", want: "new"},
+		{dflt: "'new'::character varying", want: "new"},
+		{dflt: "'it''s'", want: "it's"},
+		{dflt: "false", want: "0"},
+		{dflt: "TRUE", want: "1"},
+		{dflt: "current_timestamp()", want: "current_timestamp"},
+		{dflt: "0", want: "0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.dflt, func(t *testing.T) {
+			assert.Equal(t, tc.want, normalizeDefault(tc.dflt))
+		})
+	}
+}
+
+func TestMigrator_Migrate(t *testing.T) {
+	db := memoryDB(t, "migrate")
+	m := NewMigrator(db, DialectSQLite)
+	ctx := context.Background()
+
+	cnt := 0
+	migrations := []Migration{
+		{
+			Version: 2,
+			Name:    "add index",
+			Up: func(ctx context.Context, tx *sql.Tx) error {
+				cnt++
+				_, err := tx.ExecContext(ctx, "CREATE INDEX `idx_buyer` ON `order`(`buyer`)")
+				return err
+			},
+		},
+		{
+			Version: 1,
+			Name:    "create order",
+			Up: func(ctx context.Context, tx *sql.Tx) error {
+				cnt++
+				_, err := tx.ExecContext(ctx, "CREATE TABLE `order`(`id` INTEGER PRIMARY KEY, `buyer` TEXT)")
+				return err
+			},
+		},
+	}
+	// 按照版本顺序执行，不然建索引会失败
+	require.NoError(t, m.Migrate(ctx, migrations...))
+	assert.Equal(t, 2, cnt)
+	// 再执行一次不会有任何效果
+	require.NoError(t, m.Migrate(ctx, migrations...))
+	assert.Equal(t, 2, cnt)
+
+	// 失败的会回滚，并且不会被记录
+	err := m.Migrate(ctx, append(migrations, Migration{
+		Version: 3,
+		Name:    "fail",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO `order`(`id`, `buyer`) VALUES (1, 'Tom')")
+			if err != nil {
+				return err
+			}
+			return errors.New("mock error")
+		},
+	})...)
+	assert.Equal(t, errors.New("mock error"), err)
+	var rows int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM `order`").Scan(&rows))
+	assert.Equal(t, 0, rows)
+
+	applied, err := m.Applied(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, applied)
+
+	err = m.Migrate(ctx, Migration{Version: 4}, Migration{Version: 4})
+	assert.Equal(t, errs.NewErrDuplicateMigration(4), err)
+}
+
+func memoryDB(t *testing.T, name string) *sql.DB {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s.db?cache=shared&mode=memory", name))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+type OrderV1 struct {
+	Id    int64 `orm:"pk,auto_increment"`
+	Buyer string
+}
+
+func (o OrderV1) TableName() string {
+	return "order"
+}
+
+type OrderV2 struct {
+	Id     int64 `orm:"pk,auto_increment"`
+	Buyer  string
+	Amount int64
+	Remark *string
+	Status string `orm:"default='new'"`
+}
+
+func (o OrderV2) TableName() string {
+	return "order"
+}
+
+type OrderType struct {
+	Id     int64 `orm:"pk,auto_increment"`
+	Amount float64
+}
+
+func (o OrderType) TableName() string {
+	return "order"
+}
+
+type OrderNull struct {
+	Id     int64 `orm:"pk,auto_increment"`
+	Remark string
+}
+
+func (o OrderNull) TableName() string {
+	return "order"
+}
+
+type OrderDefault struct {
+	Id     int64  `orm:"pk,auto_increment"`
+	Status string `orm:"default='paid'"`
+}
+
+func (o OrderDefault) TableName() string {
+	return "order"
+}
+
+type OrderV3 struct {
+	Id        int64 `orm:"pk,auto_increment"`
+	Buyer     string
+	Amount    int64
+	Remark    *string
+	Status    string `orm:"default='new'"`
+	CreatedAt time.Time
+	Data      []byte
+}
+
+func (o OrderV3) TableName() string {
+	return "order"
+}
+
+type OrderUnknown struct {
+	Id    int64    `orm:"pk,auto_increment"`
+	Extra struct{} `orm:"type=JSON"`
+}
+
+func (o OrderUnknown) TableName() string {
+	return "order"
+}
@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"reflect"
+	"time"
+)
+
+// logicalType 是和方言无关的列类型，每个方言再映射成自己的类型
+type logicalType int
+
+const (
+	typeUnknown logicalType = iota
+	typeBool
+	typeInt8
+	typeInt16
+	typeInt32
+	typeInt64
+	typeUint8
+	typeUint16
+	typeUint32
+	typeUint64
+	typeFloat32
+	typeFloat64
+	typeString
+	typeBytes
+	typeTime
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	// sql.NullXXX 映射为对应的基本类型，并且允许 NULL
+	nullTypes = map[reflect.Type]logicalType{
+		reflect.TypeOf(sql.NullBool{}):    typeBool,
+		reflect.TypeOf(sql.NullByte{}):    typeUint8,
+		reflect.TypeOf(sql.NullInt16{}):   typeInt16,
+		reflect.TypeOf(sql.NullInt32{}):   typeInt32,
+		reflect.TypeOf(sql.NullInt64{}):   typeInt64,
+		reflect.TypeOf(sql.NullFloat64{}): typeFloat64,
+		reflect.TypeOf(sql.NullString{}):  typeString,
+		reflect.TypeOf(sql.NullTime{}):    typeTime,
+	}
+)
+
+// logicalTypeOf 推断字段的类型
+func logicalTypeOf(fd *model.Field) logicalType {
+	typ := fd.Type
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == timeType {
+		return typeTime
+	}
+	if lt, ok := nullTypes[typ]; ok {
+		return lt
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return typeBool
+	case reflect.Int8:
+		return typeInt8
+	case reflect.Int16:
+		return typeInt16
+	case reflect.Int32:
+		return typeInt32
+	case reflect.Int, reflect.Int64:
+		return typeInt64
+	case reflect.Uint8:
+		return typeUint8
+	case reflect.Uint16:
+		return typeUint16
+	case reflect.Uint32:
+		return typeUint32
+	case reflect.Uint, reflect.Uint64:
+		return typeUint64
+	case reflect.Float32:
+		return typeFloat32
+	case reflect.Float64:
+		return typeFloat64
+	case reflect.String:
+		return typeString
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return typeBytes
+		}
+	}
+	return typeUnknown
+}
+
+// nullable 指针、sql.NullXXX 或者打了 nullable 标签的字段允许 NULL，主键不允许
+func nullable(fd *model.Field) bool {
+	if fd.PrimaryKey {
+		return false
+	}
+	if fd.Nullable || fd.Type.Kind() == reflect.Pointer {
+		return true
+	}
+	_, ok := nullTypes[fd.Type]
+	return ok
+}
+
+// Column 是数据库里面已经存在的列
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+	// Default 是数据库返回的默认值的原始写法，不同的数据库写法不一样
+	Default sql.NullString
+}
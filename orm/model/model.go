@@ -1,15 +1,43 @@
 package model
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 )
 
 const (
-	tagKeyColumn = "column"
+	tagKeyColumn        = "column"
+	tagKeyIgnore        = "-"
+	tagKeyPrimaryKey    = "pk"
+	tagKeyAutoIncrement = "auto_increment"
+	tagKeyNullable      = "nullable"
+	tagKeyDefault       = "default"
+	tagKeySize          = "size"
+	tagKeyType          = "type"
+	tagKeyIndex         = "index"
+	tagKeyUnique        = "unique"
+)
+
+// flagTagKeys 这些 key 可以不用写值，例如 orm:"pk,auto_increment"
+var flagTagKeys = map[string]struct{}{
+	tagKeyPrimaryKey:    {},
+	tagKeyAutoIncrement: {},
+	tagKeyNullable:      {},
+	tagKeyIndex:         {},
+	tagKeyUnique:        {},
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
 )
 
 type Registry interface {
@@ -38,6 +66,35 @@ type Field struct {
 
 	// 字段相对于结构体本身的偏移量
 	Offset uintptr
+
+	// 下面的元数据都来自 orm 标签，或者 Option
+
+	// PrimaryKey 是否是主键，多个字段都是主键的话就是联合主键
+	PrimaryKey bool
+	// AutoIncrement 自增列，INSERT 的时候默认不会插入这一列
+	AutoIncrement bool
+	Nullable      bool
+	// Default 默认值，是 SQL 表达式，例如 0、'abc'、CURRENT_TIMESTAMP
+	Default string
+	// Size 长度，例如 VARCHAR(255) 的 255
+	Size int
+	// SQLType 列的类型，例如 DECIMAL(10,2)，设置了之后就不会根据 Type 推断
+	SQLType string
+	// Index 索引名，多个字段用同一个名字就是联合索引
+	Index string
+	// Unique 唯一索引名，用法和 Index 一样
+	Unique string
+}
+
+// PrimaryKeys 返回所有的主键，按照字段定义的顺序
+func (m *Model) PrimaryKeys() []*Field {
+	var res []*Field
+	for _, fd := range m.Fields {
+		if fd.PrimaryKey {
+			res = append(res, fd)
+		}
+	}
+	return res
 }
 
 
@@ -101,34 +158,27 @@ func  (r *registry) Register(entity any, opts...Option) (*Model, error) {
 	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
 		return nil, errs.ErrPointerOnly
 	}
-	elemType := typ.Elem()
-	numField := elemType.NumField()
-	fieldMap := make(map[string]*Field, numField)
-	columnMap := make(map[string]*Field, numField)
-	fields := make([]*Field, 0, numField)
-	for i := 0; i < numField; i++ {
-		fd := elemType.Field(i)
-		pair, err := r.parseTag(fd.Tag)
+	res, err := r.newModel(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		err := opt(res)
 		if err != nil {
 			return nil, err
 		}
-		colName := pair[tagKeyColumn]
-		if colName == "" {
-			// 用户没有设置
-			colName = underscoreName(fd.Name)
-		}
-		fdMeta := &Field{
-			GoName:  fd.Name,
-			ColName: colName,
-			// 字段类型
-			Type:   fd.Type,
-			Offset: fd.Offset,
-		}
-		fieldMap[fd.Name] = fdMeta
-		columnMap[colName] = fdMeta
-		fields = append(fields, fdMeta)
 	}
+	r.models.Store(typ, res)
+	return res, nil
+}
 
+// newModel 优先使用 ormgen 生成的元数据，没有的话才解析结构体
+func (r *registry) newModel(entity any) (*Model, error) {
+	if p, ok := entity.(Prebuilt); ok {
+		return p.OrmModel()
+	}
+	elemType := reflect.TypeOf(entity).Elem()
 	var tableName string
 	if tbl, ok :=entity.(TableName); ok {
 		tableName = tbl.TableName()
@@ -136,23 +186,100 @@ func  (r *registry) Register(entity any, opts...Option) (*Model, error) {
 	if tableName == "" {
 		tableName = underscoreName(elemType.Name())
 	}
+	fds, err := r.parseFields(tableName, elemType, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	// 和 Go 的规则一样，外层的字段会覆盖组合进来的同名字段
+	depths := make(map[string]int, len(fds))
+	for _, fd := range fds {
+		if d, ok := depths[fd.GoName]; !ok || fd.depth < d {
+			depths[fd.GoName] = fd.depth
+		}
+	}
+	fields := make([]*Field, 0, len(fds))
+	for _, fd := range fds {
+		if fd.depth == depths[fd.GoName] {
+			fields = append(fields, fd.Field)
+		}
+	}
+	return NewModel(tableName, fields)
+}
 
-
-	res := &Model{
+// NewModel 用已经解析好的字段创建元数据，同名的字段会返回错误。
+// ormgen 生成的代码就是用它来构造 Model 的
+func NewModel(tableName string, fields []*Field) (*Model, error) {
+	fieldMap := make(map[string]*Field, len(fields))
+	columnMap := make(map[string]*Field, len(fields))
+	for _, fd := range fields {
+		if _, ok := fieldMap[fd.GoName]; ok {
+			return nil, errs.NewErrAmbiguousField(fd.GoName)
+		}
+		fieldMap[fd.GoName] = fd
+		columnMap[fd.ColName] = fd
+	}
+	return &Model{
 		TableName: tableName,
 		FieldMap:  fieldMap,
 		ColumnMap: columnMap,
 		Fields: fields,
-	}
+	}, nil
+}
 
-	for _, opt := range opts {
-		err := opt(res)
+type fieldWithDepth struct {
+	*Field
+	// depth 是组合的层数，最外层是 0
+	depth int
+}
+
+// parseFields 解析 typ 的字段，组合进来的结构体会被展开，
+// offset 是 typ 相对于最外层结构体的偏移量
+func (r *registry) parseFields(tableName string, typ reflect.Type, offset uintptr, depth int) ([]fieldWithDepth, error) {
+	numField := typ.NumField()
+	fields := make([]fieldWithDepth, 0, numField)
+	for i := 0; i < numField; i++ {
+		fd := typ.Field(i)
+		pair, err := r.parseTag(fd.Tag)
 		if err != nil {
 			return nil, err
 		}
+		if _, ok := pair[tagKeyIgnore]; ok {
+			continue
+		}
+		if fd.Anonymous && isEmbeddedStruct(fd.Type) {
+			subs, err := r.parseFields(tableName, fd.Type, offset+fd.Offset, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, subs...)
+			continue
+		}
+		if fd.Anonymous && fd.Type.Kind() == reflect.Ptr && isEmbeddedStruct(fd.Type.Elem()) {
+			// 指针没法通过偏移量计算地址
+			return nil, errs.NewErrEmbeddedPointer(fd.Name)
+		}
+		fdMeta, err := r.newField(tableName, fd.Name, pair)
+		if err != nil {
+			return nil, err
+		}
+		// 字段类型
+		fdMeta.Type = fd.Type
+		fdMeta.Offset = offset + fd.Offset
+		fields = append(fields, fieldWithDepth{
+			Field: fdMeta,
+			depth: depth,
+		})
 	}
-	r.models.Store(typ, res)
-	return res, nil
+	return fields, nil
+}
+
+// isEmbeddedStruct 判断组合进来的类型是否需要展开
+// time.Time、sql.NullString 这种自己能处理读写的结构体，还是当成一列
+func isEmbeddedStruct(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return false
+	}
+	return !reflect.PtrTo(typ).Implements(scannerType) && !typ.Implements(valuerType)
 }
 
 func WithTableName(tableName string) Option {
@@ -171,7 +298,73 @@ func WithColumnName(field string, colName string) Option {
 		if !ok {
 			return errs.NewErrUnknownField(field)
 		}
+		delete(m.ColumnMap, fd.ColName)
 		fd.ColName = colName
+		m.ColumnMap[colName] = fd
+		return nil
+	}
+}
+
+// WithPrimaryKey 设置主键，传入多个字段就是联合主键
+func WithPrimaryKey(fields ...string) Option {
+	return withFields(fields, func(fd *Field) {
+		fd.PrimaryKey = true
+	})
+}
+
+func WithAutoIncrement(field string) Option {
+	return withFields([]string{field}, func(fd *Field) {
+		fd.AutoIncrement = true
+	})
+}
+
+func WithNullable(field string) Option {
+	return withFields([]string{field}, func(fd *Field) {
+		fd.Nullable = true
+	})
+}
+
+func WithDefault(field string, val string) Option {
+	return withFields([]string{field}, func(fd *Field) {
+		fd.Default = val
+	})
+}
+
+func WithSize(field string, size int) Option {
+	return withFields([]string{field}, func(fd *Field) {
+		fd.Size = size
+	})
+}
+
+func WithSQLType(field string, typ string) Option {
+	return withFields([]string{field}, func(fd *Field) {
+		fd.SQLType = typ
+	})
+}
+
+// WithIndex 在 fields 上建立名字为 name 的索引
+func WithIndex(name string, fields ...string) Option {
+	return withFields(fields, func(fd *Field) {
+		fd.Index = name
+	})
+}
+
+// WithUnique 在 fields 上建立名字为 name 的唯一索引
+func WithUnique(name string, fields ...string) Option {
+	return withFields(fields, func(fd *Field) {
+		fd.Unique = name
+	})
+}
+
+func withFields(fields []string, fn func(fd *Field)) Option {
+	return func(m *Model) error {
+		for _, field := range fields {
+			fd, ok := m.FieldMap[field]
+			if !ok {
+				return errs.NewErrUnknownField(field)
+			}
+			fn(fd)
+		}
 		return nil
 	}
 }
@@ -181,11 +374,20 @@ func  (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 	if !ok {
 		return map[string]string{}, nil
 	}
-	pairs := strings.Split(ormTag, ",")
+	// orm:"-" 代表忽略这个字段
+	if ormTag == tagKeyIgnore {
+		return map[string]string{tagKeyIgnore: ""}, nil
+	}
+	pairs := splitTag(ormTag)
 	res := make(map[string]string, len(pairs))
 	for _, pair := range pairs {
-		segs := strings.Split(pair, "=")
+		// 默认值里面可能有 =，所以只切一次
+		segs := strings.SplitN(pair, "=", 2)
 		if len(segs) != 2 {
+			if _, ok := flagTagKeys[pair]; ok {
+				res[pair] = ""
+				continue
+			}
 			return nil, errs.NewErrInvalidTagContent(pair)
 		}
 		key := segs[0]
@@ -195,6 +397,92 @@ func  (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 	return res, nil
 }
 
+// splitTag 按照逗号切割，但是不会切割括号里面的逗号，例如 type=DECIMAL(10,2)
+func splitTag(tag string) []string {
+	var res []string
+	depth, start := 0, 0
+	for i, c := range tag {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				res = append(res, tag[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, tag[start:])
+}
+
+// parseFieldTag 把标签里面除了列名以外的元数据设置到 fd 上
+func (r *registry) parseFieldTag(tableName string, fd *Field, pair map[string]string) error {
+	_, fd.PrimaryKey = pair[tagKeyPrimaryKey]
+	_, fd.AutoIncrement = pair[tagKeyAutoIncrement]
+	_, fd.Nullable = pair[tagKeyNullable]
+	fd.Default = pair[tagKeyDefault]
+	fd.SQLType = pair[tagKeyType]
+	if size, ok := pair[tagKeySize]; ok {
+		val, err := strconv.Atoi(size)
+		if err != nil || val <= 0 {
+			return errs.NewErrInvalidTagContent(tagKeySize + "=" + size)
+		}
+		fd.Size = val
+	}
+	// 没有指定名字的时候，用表名和列名生成一个，
+	// 因为 PostgreSQL 和 SQLite 里面索引名在不同的表之间也不能重复
+	if idx, ok := pair[tagKeyIndex]; ok {
+		if idx == "" {
+			idx = "idx_" + tableName + "_" + fd.ColName
+		}
+		fd.Index = idx
+	}
+	if uk, ok := pair[tagKeyUnique]; ok {
+		if uk == "" {
+			uk = "uk_" + tableName + "_" + fd.ColName
+		}
+		fd.Unique = uk
+	}
+	return nil
+}
+
+// NewField 根据 orm 标签创建字段的元数据，返回 nil 代表这个字段被忽略了。
+// 给 ormgen 这种拿不到 reflect.StructField 的场景用，Type 和 Offset 要自己设置
+func NewField(tableName string, goName string, tag reflect.StructTag) (*Field, error) {
+	r := &registry{}
+	pair, err := r.parseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := pair[tagKeyIgnore]; ok {
+		return nil, nil
+	}
+	return r.newField(tableName, goName, pair)
+}
+
+func (r *registry) newField(tableName string, goName string, pair map[string]string) (*Field, error) {
+	colName := pair[tagKeyColumn]
+	if colName == "" {
+		// 用户没有设置
+		colName = underscoreName(goName)
+	}
+	fd := &Field{
+		GoName:  goName,
+		ColName: colName,
+	}
+	if err := r.parseFieldTag(tableName, fd, pair); err != nil {
+		return nil, err
+	}
+	return fd, nil
+}
+
+// UnderscoreName 驼峰转下划线，默认的表名和列名都是这么来的
+func UnderscoreName(name string) string {
+	return underscoreName(name)
+}
+
 // underscoreName 驼峰转字符串命名
 func underscoreName(tableName string) string {
 	var buf []byte
@@ -214,4 +502,10 @@ func underscoreName(tableName string) string {
 
 type TableName interface {
 	TableName() string
+}
+
+// Prebuilt 一般是 ormgen 生成的，实现了这个接口的模型注册的时候
+// 直接使用 OrmModel 返回的元数据，不会再通过反射解析结构体
+type Prebuilt interface {
+	OrmModel() (*Model, error)
 }
\ No newline at end of file
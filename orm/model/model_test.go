@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_registry_Register(t *testing.T) {
@@ -53,6 +54,116 @@ func Test_registry_Register(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "embedded",
+			entity: &EmbeddedModel{},
+			wantModel: &Model{
+				TableName: "embedded_model",
+				Fields: []*Field{
+					{
+						ColName: "id",
+						GoName:  "Id",
+						Type:    reflect.TypeOf(int64(0)),
+					},
+					{
+						ColName: "created_at",
+						GoName:  "CreatedAt",
+						Type:    reflect.TypeOf(time.Time{}),
+						Offset:  8,
+					},
+					{
+						ColName: "age",
+						GoName:  "Age",
+						Type:    reflect.TypeOf(int8(0)),
+						Offset:  32,
+					},
+					{
+						ColName: "name",
+						GoName:  "Name",
+						Type:    reflect.TypeOf(""),
+						Offset:  40,
+					},
+					{
+						ColName: "nick_name",
+						GoName:  "NickName",
+						Type:    reflect.TypeOf(&sql.NullString{}),
+						Offset:  72,
+					},
+				},
+			},
+		},
+		{
+			// 外层的字段覆盖组合进来的字段
+			name: "shadow",
+			entity: func() any {
+				type ShadowModel struct {
+					BaseModel
+					Id string
+				}
+				return &ShadowModel{}
+			}(),
+			wantModel: &Model{
+				TableName: "shadow_model",
+				Fields: []*Field{
+					{
+						ColName: "created_at",
+						GoName:  "CreatedAt",
+						Type:    reflect.TypeOf(time.Time{}),
+						Offset:  8,
+					},
+					{
+						ColName: "id",
+						GoName:  "Id",
+						Type:    reflect.TypeOf(""),
+						Offset:  32,
+					},
+				},
+			},
+		},
+		{
+			// 实现了 sql.Scanner 的结构体不会被展开
+			name: "embedded scanner",
+			entity: func() any {
+				type ScannerModel struct {
+					sql.NullString
+				}
+				return &ScannerModel{}
+			}(),
+			wantModel: &Model{
+				TableName: "scanner_model",
+				Fields: []*Field{
+					{
+						ColName: "null_string",
+						GoName:  "NullString",
+						Type:    reflect.TypeOf(sql.NullString{}),
+					},
+				},
+			},
+		},
+		{
+			name: "ambiguous",
+			entity: func() any {
+				type OtherBase struct {
+					Id int64
+				}
+				type AmbiguousModel struct {
+					BaseModel
+					OtherBase
+				}
+				return &AmbiguousModel{}
+			}(),
+			wantErr: errs.NewErrAmbiguousField("Id"),
+		},
+		{
+			name: "embedded pointer",
+			entity: func() any {
+				type PtrModel struct {
+					*BaseModel
+				}
+				return &PtrModel{}
+			}(),
+			wantErr: errs.NewErrEmbeddedPointer("BaseModel"),
+		},
 		{
 			name: "map",
 			entity: map[string]string{},
@@ -179,6 +290,73 @@ func TestRegistry_get(t *testing.T) {
 			}(),
 			wantErr: errs.NewErrInvalidTagContent("column"),
 		},
+		{
+			name: "full tags",
+			entity: func() any {
+				type FullTagTable struct {
+					Id       int64   `orm:"pk,auto_increment"`
+					Email    string  `orm:"column=mail,size=128,unique"`
+					Price    float64 `orm:"type=DECIMAL(10,2),default=0,index=idx_price_name"`
+					Name     *string `orm:"nullable,index=idx_price_name"`
+					Status   string  `orm:"default='a=b'"`
+				}
+				return &FullTagTable{}
+			}(),
+			wantModel: &Model{
+				TableName: "full_tag_table",
+				Fields: []*Field{
+					{
+						ColName:       "id",
+						GoName:        "Id",
+						Type:          reflect.TypeOf(int64(0)),
+						PrimaryKey:    true,
+						AutoIncrement: true,
+					},
+					{
+						ColName: "mail",
+						GoName:  "Email",
+						Type:    reflect.TypeOf(""),
+						Offset:  8,
+						Size:    128,
+						Unique:  "uk_full_tag_table_mail",
+					},
+					{
+						ColName: "price",
+						GoName:  "Price",
+						Type:    reflect.TypeOf(float64(0)),
+						Offset:  24,
+						SQLType: "DECIMAL(10,2)",
+						Default: "0",
+						Index:   "idx_price_name",
+					},
+					{
+						ColName:  "name",
+						GoName:   "Name",
+						Type:     reflect.TypeOf(new(string)),
+						Offset:   32,
+						Nullable: true,
+						Index:    "idx_price_name",
+					},
+					{
+						ColName: "status",
+						GoName:  "Status",
+						Type:    reflect.TypeOf(""),
+						Offset:  40,
+						Default: "'a=b'",
+					},
+				},
+			},
+		},
+		{
+			name: "invalid size",
+			entity: func() any {
+				type TagTable struct {
+					FirstName string `orm:"size=abc"`
+				}
+				return &TagTable{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("size=abc"),
+		},
 		{
 			name: "ignore tag",
 			entity: func() any {
@@ -335,10 +513,96 @@ func TestModelWithColumnName(t *testing.T) {
 			fd, ok := m.FieldMap[tc.field]
 			require.True(t, ok)
 			assert.Equal(t, tc.wantColName, fd.ColName)
+			assert.Equal(t, fd, m.ColumnMap[tc.wantColName])
+		})
+	}
+}
+
+func TestModelWithOptions(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []Option
+
+		wantField *Field
+		wantPKs   []string
+		wantErr   error
+	}{
+		{
+			name: "primary key",
+			opts: []Option{WithPrimaryKey("Id", "FirstName"), WithAutoIncrement("Id")},
+			wantField: &Field{
+				ColName:       "id",
+				GoName:        "Id",
+				Type:          reflect.TypeOf(int64(0)),
+				PrimaryKey:    true,
+				AutoIncrement: true,
+			},
+			wantPKs: []string{"Id", "FirstName"},
+		},
+		{
+			name: "column",
+			opts: []Option{
+				WithSize("Id", 20),
+				WithSQLType("Id", "BIGINT"),
+				WithDefault("Id", "1"),
+				WithNullable("Id"),
+				WithIndex("idx_id_age", "Id", "Age"),
+				WithUnique("uk_id", "Id"),
+			},
+			wantField: &Field{
+				ColName:  "id",
+				GoName:   "Id",
+				Type:     reflect.TypeOf(int64(0)),
+				Size:     20,
+				SQLType:  "BIGINT",
+				Default:  "1",
+				Nullable: true,
+				Index:    "idx_id_age",
+				Unique:   "uk_id",
+			},
+		},
+		{
+			name:    "unknown field",
+			opts:    []Option{WithIndex("idx_id_age", "Id", "XXX")},
+			wantErr: errs.NewErrUnknownField("XXX"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry()
+			m, err := r.Register(&TestModel{}, tc.opts...)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantField, m.FieldMap["Id"])
+			var pks []string
+			for _, fd := range m.PrimaryKeys() {
+				pks = append(pks, fd.GoName)
+			}
+			assert.Equal(t, tc.wantPKs, pks)
 		})
 	}
 }
 
+type BaseModel struct {
+	Id        int64
+	CreatedAt time.Time
+}
+
+type EmbeddedModel struct {
+	User
+	Name     string
+	Password string `orm:"-"`
+	NickName *sql.NullString
+}
+
+type User struct {
+	BaseModel
+	Age int8
+}
+
 type TestModel struct {
 	Id        int64
 	// ""
@@ -346,3 +610,42 @@ type TestModel struct {
 	Age       int8
 	LastName  *sql.NullString
 }
+
+func TestRegistry_Prebuilt(t *testing.T) {
+	r := NewRegistry()
+	m, err := r.Register(&PrebuiltModel{}, WithColumnName("Name", "name_t"))
+	require.NoError(t, err)
+	assert.Equal(t, "prebuilt", m.TableName)
+	assert.Equal(t, "Name", m.ColumnMap["name_t"].GoName)
+	_, ok := m.ColumnMap["name"]
+	assert.False(t, ok)
+
+	_, err = NewModel("prebuilt", []*Field{{GoName: "Id"}, {GoName: "Id"}})
+	assert.Equal(t, errs.NewErrAmbiguousField("Id"), err)
+}
+
+func TestNewField(t *testing.T) {
+	fd, err := NewField("user", "FirstName", `orm:"column=name,index"`)
+	require.NoError(t, err)
+	assert.Equal(t, &Field{GoName: "FirstName", ColName: "name", Index: "idx_user_name"}, fd)
+
+	fd, err = NewField("user", "FirstName", `orm:"-"`)
+	require.NoError(t, err)
+	assert.Nil(t, fd)
+
+	_, err = NewField("user", "FirstName", `orm:"abc"`)
+	assert.Equal(t, errs.NewErrInvalidTagContent("abc"), err)
+}
+
+// PrebuiltModel 模拟 ormgen 生成的代码
+type PrebuiltModel struct {
+	Id   int64
+	Name string
+}
+
+func (*PrebuiltModel) OrmModel() (*Model, error) {
+	return NewModel("prebuilt", []*Field{
+		{GoName: "Id", ColName: "id", PrimaryKey: true},
+		{GoName: "Name", ColName: "name"},
+	})
+}
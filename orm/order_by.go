@@ -0,0 +1,26 @@
+package orm
+
+// OrderBy 代表 ORDER BY 的一项
+type OrderBy struct {
+	// col 是字段名，也可以是 SELECT 部分的别名
+	col   string
+	order string
+}
+
+// Asc 升序
+// Asc("Age")
+func Asc(col string) OrderBy {
+	return OrderBy{
+		col:   col,
+		order: "ASC",
+	}
+}
+
+// Desc 降序
+// Desc("Id")
+func Desc(col string) OrderBy {
+	return OrderBy{
+		col:   col,
+		order: "DESC",
+	}
+}
@@ -5,10 +5,28 @@ type op string
 
 const (
 	opEq op = "="
+	opNotEq op = "!="
 	opLT op = "<"
+	opLTE op = "<="
+	opGT op = ">"
+	opGTE op = ">="
+	opNotIn op = "NOT IN"
+	opLike op = "LIKE"
+	opNotLike op = "NOT LIKE"
+	opBetween op = "BETWEEN"
+	opIsNull op = "IS NULL"
+	opIsNotNull op = "IS NOT NULL"
 	opNot op = "NOT"
 	opAnd op = "AND"
 	opOr op = "OR"
+	opIn op = "IN"
+	opExists op = "EXISTS"
+	opNotExists op = "NOT EXISTS"
+
+	opAdd op = "+"
+	opSub op = "-"
+	opMulti op = "*"
+	opDiv op = "/"
 )
 
 func (o op) String() string {
@@ -46,6 +64,22 @@ func Not(p Predicate) Predicate {
 	}
 }
 
+// Exists(sub)
+func Exists(sub Subquery) Predicate {
+	return Predicate{
+		op:    opExists,
+		right: sub,
+	}
+}
+
+// NotExists(sub)
+func NotExists(sub Subquery) Predicate {
+	return Predicate{
+		op:    opNotExists,
+		right: sub,
+	}
+}
+
 // C("id").Eq(12).And(C("name").Eq("Tom"))
 func (left Predicate) And(right Predicate) Predicate {
 	return Predicate{
@@ -72,5 +106,20 @@ type value struct {
 
 func (value) expr(){}
 
+// values 代表 IN 后面的一组值，构造成 (?,?,?)
+type values struct {
+	vals []any
+}
+
+func (values) expr(){}
+
+// betweenRange 代表 BETWEEN 后面的 low AND high
+type betweenRange struct {
+	low Expression
+	high Expression
+}
+
+func (betweenRange) expr(){}
+
 
 
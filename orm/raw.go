@@ -0,0 +1,99 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"reflect"
+	"time"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// RawQuerier 执行原生查询，并且把结果映射为 T
+// T 可以是模型，也可以是 int64、string 之类的基本类型
+type RawQuerier[T any] struct {
+	core
+	sess Session
+	sql  string
+	args []any
+}
+
+var _ Querier[any] = &RawQuerier[any]{}
+
+// RawQuery 用法：
+//
+//	u, err := RawQuery[User](db, "SELECT * FROM `user` WHERE `id` = ?", 1).Get(ctx)
+//	cnt, err := RawQuery[int64](db, "SELECT COUNT(*) FROM `user`").Get(ctx)
+func RawQuery[T any](sess Session, query string, args ...any) *RawQuerier[T] {
+	return &RawQuerier[T]{
+		core: sess.getCore(),
+		sess: sess,
+		sql:  query,
+		args: args,
+	}
+}
+
+func (r *RawQuerier[T]) Build() (*Query, error) {
+	return &Query{
+		SQL:  r.dialect.rebind(r.sql),
+		Args: r.args,
+	}, nil
+}
+
+func (r *RawQuerier[T]) Get(ctx context.Context) (*T, error) {
+	m, err := resultModel[T](r.r)
+	if err != nil {
+		return nil, err
+	}
+	q, err := r.Build()
+	if err != nil {
+		return nil, err
+	}
+	res := r.handle(ctx, r.newQueryContext(q, m, reflect.TypeOf(new(T))), getHandler[T](r.sess, m))
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	tp, _ := res.Result.(*T)
+	return tp, nil
+}
+
+func (r *RawQuerier[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	m, err := resultModel[T](r.r)
+	if err != nil {
+		return nil, err
+	}
+	q, err := r.Build()
+	if err != nil {
+		return nil, err
+	}
+	res := r.handle(ctx, r.newQueryContext(q, m, reflect.TypeOf([]*T{})), getMultiHandler[T](r.sess, m))
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	tps, _ := res.Result.([]*T)
+	return tps, nil
+}
+
+func (r *RawQuerier[T]) newQueryContext(q *Query, m *model.Model, typ reflect.Type) *QueryContext {
+	return &QueryContext{
+		Type:        "SELECT",
+		Builder:     r,
+		Model:       m,
+		Query:       q,
+		ResultType:  typ,
+		ResultModel: m,
+	}
+}
+
+// isScalar 判断 typ 能不能直接传给 rows.Scan
+// 除了基本类型，time.Time 和 sql.NullString 这种实现了 sql.Scanner 的结构体也可以
+func isScalar(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return true
+	}
+	return typ == timeType || reflect.PointerTo(typ).Implements(scannerType)
+}
@@ -0,0 +1,135 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRawQuerier_Get(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	// query error
+	mock.ExpectQuery("SELECT .*").WillReturnError(errors.New("query error"))
+
+	// no rows
+	rows := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	// data
+	rows = sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	rows.AddRow("1", "Tom", "18", "Jerry")
+	mock.ExpectQuery("SELECT \\* FROM `test_model` WHERE `id` = \\?").
+		WithArgs(1).WillReturnRows(rows)
+
+	testCases := []struct {
+		name string
+		q    *RawQuerier[TestModel]
+
+		wantErr error
+		wantRes *TestModel
+	}{
+		{
+			name:    "query error",
+			q:       RawQuery[TestModel](db, "SELECT * FROM `test_model`"),
+			wantErr: errors.New("query error"),
+		},
+		{
+			name:    "no rows",
+			q:       RawQuery[TestModel](db, "SELECT * FROM `test_model`"),
+			wantErr: ErrNoRows,
+		},
+		{
+			name: "data",
+			q:    RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` = ?", 1),
+			wantRes: &TestModel{
+				Id:        1,
+				FirstName: "Tom",
+				Age:       18,
+				LastName:  &sql.NullString{Valid: true, String: "Jerry"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.q.Get(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRawQuerier_GetMulti(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"id", "first_name"})
+	rows.AddRow("1", "Tom")
+	rows.AddRow("2", "Jerry")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	res, err := RawQuery[TestModel](db, "SELECT `id`, `first_name` FROM `test_model`").
+		GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{{Id: 1, FirstName: "Tom"}, {Id: 2, FirstName: "Jerry"}}, res)
+
+	// 未知的列
+	rows = sqlmock.NewRows([]string{"id", "invalid"})
+	rows.AddRow("1", "Tom")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	_, err = RawQuery[TestModel](db, "SELECT `id`, `invalid` FROM `test_model`").
+		GetMulti(context.Background())
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRawQuerier_Scalar(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT COUNT.*").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(10))
+	cnt, err := RawQuery[int64](db, "SELECT COUNT(*) FROM `test_model`").Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), *cnt)
+
+	mock.ExpectQuery("SELECT `first_name`.*").
+		WillReturnRows(sqlmock.NewRows([]string{"first_name"}).AddRow("Tom").AddRow("Jerry"))
+	names, err := RawQuery[string](db, "SELECT `first_name` FROM `test_model`").GetMulti(context.Background())
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+	assert.Equal(t, "Tom", *names[0])
+	assert.Equal(t, "Jerry", *names[1])
+
+	// 实现了 sql.Scanner 的结构体也当作基本类型
+	mock.ExpectQuery("SELECT `last_name`.*").
+		WillReturnRows(sqlmock.NewRows([]string{"last_name"}).AddRow(nil))
+	ln, err := RawQuery[sql.NullString](db, "SELECT `last_name` FROM `test_model` LIMIT 1").Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, sql.NullString{}, *ln)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRawQuerier_Build(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(DialectPostgreSQL))
+	q, err := RawQuery[TestModel](db, "SELECT * FROM \"test_model\" WHERE \"id\" = ? AND \"first_name\" = '?'", 1).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT * FROM \"test_model\" WHERE \"id\" = $1 AND \"first_name\" = '?'",
+		Args: []any{1},
+	}, q)
+}
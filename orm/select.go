@@ -2,7 +2,11 @@ package orm
 
 import (
 	"context"
+	"database/sql"
 	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/valuer"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"reflect"
 )
 
 // Selectable 是一个标记接口
@@ -14,10 +18,15 @@ type Selectable interface {
 
 type Selector[T any] struct {
 	builder
-	table string
+	table TableReference
 	where []Predicate
 	columns []Selectable
-	db *DB
+	groupBy []Column
+	having []Predicate
+	orderBy []OrderBy
+	limit int
+	offset int
+	sess Session
 }
 
 // func (db *DB) NewSelector[T any]()*Selector[T] {
@@ -27,13 +36,10 @@ type Selector[T any] struct {
 // 	}
 // }
 
-func NewSelector[T any](db *DB) *Selector[T] {
+func NewSelector[T any](sess Session) *Selector[T] {
 	return &Selector[T]{
-		builder: builder{
-			dialect: db.dialect,
-			quoter: db.dialect.quoter(),
-		},
-		db: db,
+		builder: newBuilder(sess),
+		sess:    sess,
 	}
 }
 
@@ -42,8 +48,24 @@ func NewSelector[T any](db *DB) *Selector[T] {
 // }
 
 func (s *Selector[T]) Build() (*Query, error) {
+	q, err := s.build()
+	if err != nil {
+		return nil, err
+	}
+	q.SQL = s.dialect.rebind(q.SQL)
+	return q, nil
+}
+
+// build 构造 SELECT 语句，但是不会改写占位符
+func (s *Selector[T]) build() (*Query, error) {
+	// 作为子查询的时候，每构造一次外层查询就会构造一次子查询
+	s.sb.Reset()
+	s.args = nil
+	s.argCols = nil
+	s.tables = nil
+	s.aliases = nil
 	var err error
-	s.model, err = s.db.r.Get(new(T))
+	s.model, err = s.r.Get(new(T))
 	if err != nil {
 		return nil, err
 	}
@@ -55,33 +77,56 @@ func (s *Selector[T]) Build() (*Query, error) {
 	}
 
 	s.sb.WriteString(" FROM ")
-	// 我怎么把表名拿到
-	if s.table == "" {
-		s.sb.WriteByte('`')
-		s.sb.WriteString(s.model.TableName)
-		s.sb.WriteByte('`')
-	} else {
-		// segs := strings.Split(s.table, ".")
-		// sb.WriteByte('`')
-		// sb.WriteString(segs[0])
-		// sb.WriteByte('`')
-		// sb.WriteByte('.')
-		// sb.WriteByte('`')
-		// sb.WriteString(segs[1])
-		// sb.WriteByte('`')
-		s.sb.WriteString(s.table)
+	if err = s.buildTable(s.table); err != nil {
+		return nil, err
 	}
 	if len(s.where) > 0 {
 		s.sb.WriteString(" WHERE ")
-		p := s.where[0]
-		for i := 1; i < len(s.where); i++ {
-			p = p.And(s.where[i])
+		if err = s.buildPredicates(s.where); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.groupBy) > 0 {
+		s.sb.WriteString(" GROUP BY ")
+		for i, c := range s.groupBy {
+			if i > 0 {
+				s.sb.WriteByte(',')
+			}
+			if err = s.buildTableColumn(c.table, c.name); err != nil {
+				return nil, err
+			}
 		}
-		if err := s.buildExpression(p); err != nil {
+	}
+
+	// HAVING 和 ORDER BY 里面是可以使用 SELECT 部分的别名的
+	s.aliases = s.selectAliases()
+
+	if len(s.having) > 0 {
+		s.sb.WriteString(" HAVING ")
+		if err = s.buildPredicates(s.having); err != nil {
 			return nil, err
 		}
 	}
 
+	if len(s.orderBy) > 0 {
+		s.sb.WriteString(" ORDER BY ")
+		for i, ob := range s.orderBy {
+			if i > 0 {
+				s.sb.WriteByte(',')
+			}
+			if err = s.buildColumn(C(ob.col)); err != nil {
+				return nil, err
+			}
+			s.sb.WriteByte(' ')
+			s.sb.WriteString(ob.order)
+		}
+	}
+
+	if s.limit > 0 || s.offset > 0 {
+		s.dialect.buildLimit(&s.builder, s.limit, s.offset)
+	}
+
 	s.sb.WriteByte(';')
 	return &Query{
 		SQL: s.sb.String(),
@@ -89,56 +134,12 @@ func (s *Selector[T]) Build() (*Query, error) {
 	}, nil
 }
 
-func (s *Selector[T]) buildExpression(expr Expression) error {
-	switch exp := expr.(type){
-	case nil:
-	case Predicate:
-		// 在这里处理 p
-		// p.left 构建好
-		// p.op 构建好
-		// p.right 构建好
-		_, ok := exp.left.(Predicate)
-		if ok {
-			s.sb.WriteByte('(')
-		}
-		if err := s.buildExpression(exp.left); err != nil {
-			return err
-		}
-		if ok {
-			s.sb.WriteByte(')')
-		}
+func (s *Selector[T]) argColumns() []string {
+	return s.argCols
+}
 
-		if exp.op != "" {
-			s.sb.WriteByte(' ')
-			s.sb.WriteString(exp.op.String())
-			s.sb.WriteByte(' ')
-		}
-		_, ok = exp.right.(Predicate)
-		if ok {
-			s.sb.WriteByte('(')
-		}
-		if err := s.buildExpression(exp.right); err != nil {
-			return err
-		}
-		if ok {
-			s.sb.WriteByte(')')
-		}
-	case Column:
-		// 这种写法很隐晦
-		exp.alias = ""
-		return s.buildColumn(exp)
-	case value:
-		s.sb.WriteByte('?')
-		s.addArg(exp.val)
-	case RawExpr:
-		s.sb.WriteByte('(')
-		s.sb.WriteString(exp.raw)
-		s.addArg(exp.args...)
-		s.sb.WriteByte(')')
-	default:
-		return errs.NewErrUnsupportedExpression(expr)
-	}
-	return nil
+func (s *Selector[T]) tableNames() []string {
+	return s.tables
 }
 
 func (s *Selector[T]) buildColumns() error {
@@ -159,23 +160,26 @@ func (s *Selector[T]) buildColumns() error {
 				return err
 			}
 		case Aggregate:
-			// 聚合函数名
-			s.sb.WriteString(c.fn)
-			s.sb.WriteByte('(')
-			err := s.buildColumn(Column{name: c.arg})
-			if err != nil {
+			if err := s.buildAggregate(c, true); err != nil {
 				return err
 			}
-			s.sb.WriteByte(')')
-			// 聚合函数本身的别名
-			if c.alias != "" {
-				s.sb.WriteString(" AS `")
-				s.sb.WriteString(c.alias)
-				s.sb.WriteByte('`')
+		case MathExpr, FuncExpr, CaseExpr:
+			if err := s.buildExpression(c.(Expression)); err != nil {
+				return err
+			}
+			if alias := selectableAlias(c); alias != "" {
+				s.sb.WriteString(" AS ")
+				s.quote(alias)
 			}
 		case RawExpr:
 			s.sb.WriteString(c.raw)
 			s.addArg(c.args...)
+		case Subquery:
+			if err := s.buildSubquery(c, true); err != nil {
+				return err
+			}
+		default:
+			return errs.NewErrUnsupportedSelectable(col)
 		}
 	}
 
@@ -183,32 +187,17 @@ func (s *Selector[T]) buildColumns() error {
 }
 
 func (s *Selector[T]) buildColumn(c Column) error {
-	fd, ok := s.model.FieldMap[c.name]
-	// 字段不对，或者说列不对
-	if !ok {
-		return errs.NewErrUnknownField(c.name)
-	}
-	s.sb.WriteByte('`')
-	s.sb.WriteString(fd.ColName)
-	s.sb.WriteByte('`')
+	err := s.buildTableColumn(c.table, c.name)
+	if err != nil {
+		return err
+	}
 	if c.alias != "" {
-		s.sb.WriteString(" AS `")
-		s.sb.WriteString(c.alias)
-		s.sb.WriteByte('`')
+		s.sb.WriteString(" AS ")
+		s.quote(c.alias)
 	}
 	return nil
 }
 
-func (s *Selector[T]) addArg(vals ...any) {
-	if len(vals) == 0 {
-		return
-	}
-	if s.args == nil {
-		s.args = make([]any, 0, 8)
-	}
-	s.args = append(s.args, vals...)
-}
-
 // 这种也是可行
 // s.Select("first_name,last_name")
 // func (s *Selector[T]) SelectV1(cols string) *Selector[T] {
@@ -225,7 +214,89 @@ func (s *Selector[T]) Select(cols...Selectable) *Selector[T] {
 	return s
 }
 
-func (s *Selector[T]) From(table string) *Selector[T] {
+// selectAliases 收集 SELECT 部分的别名
+func (s *Selector[T]) selectAliases() map[string]struct{} {
+	var res map[string]struct{}
+	for _, col := range s.columns {
+		alias := selectableAlias(col)
+		if alias == "" {
+			continue
+		}
+		if res == nil {
+			res = make(map[string]struct{}, len(s.columns))
+		}
+		res[alias] = struct{}{}
+	}
+	return res
+}
+
+// selectableAlias 返回 SELECT 部分的一项的别名，没有别名的话返回空字符串
+func selectableAlias(col Selectable) string {
+	switch c := col.(type) {
+	case Column:
+		return c.alias
+	case Aggregate:
+		return c.alias
+	case MathExpr:
+		return c.alias
+	case FuncExpr:
+		return c.alias
+	case CaseExpr:
+		return c.alias
+	}
+	return ""
+}
+
+// GroupBy 设置 GROUP BY 子句
+func (s *Selector[T]) GroupBy(cols ...Column) *Selector[T] {
+	s.groupBy = cols
+	return s
+}
+
+// Having 设置 HAVING 子句，多个条件之间用 AND 连接
+// 可以使用聚合函数，也可以直接引用 SELECT 里面的别名
+// Having(Avg("Age").Gt(18))
+// Having(C("avg_age").Gt(18))
+func (s *Selector[T]) Having(ps ...Predicate) *Selector[T] {
+	s.having = ps
+	return s
+}
+
+// OrderBy 设置 ORDER BY 子句
+// OrderBy(Asc("Age"), Desc("Id"))
+func (s *Selector[T]) OrderBy(bys ...OrderBy) *Selector[T] {
+	s.orderBy = bys
+	return s
+}
+
+func (s *Selector[T]) Limit(limit int) *Selector[T] {
+	s.limit = limit
+	return s
+}
+
+func (s *Selector[T]) Offset(offset int) *Selector[T] {
+	s.offset = offset
+	return s
+}
+
+// AsSubquery 把当前的查询作为子查询，alias 是子查询的别名
+func (s *Selector[T]) AsSubquery(alias string) Subquery {
+	table := s.table
+	if table == nil {
+		table = TableOf(new(T))
+	}
+	return Subquery{
+		s:       s,
+		alias:   alias,
+		table:   table,
+		columns: s.columns,
+	}
+}
+
+// From 指定表，如果是 nil，那么将会使用 T 对应的表
+// From(TableOf(&Order{}).As("t1"))
+// From(t1.Join(t2).On(t1.C("Id").Eq(t2.C("OrderId"))))
+func (s *Selector[T]) From(table TableReference) *Selector[T] {
 	s.table = table
 	return s
 }
@@ -304,38 +375,198 @@ func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
 	if err != nil {
 		return nil, err
 	}
+	res := s.handle(ctx, s.newQueryContext(q, reflect.TypeOf(new(T)), s.model), getHandler[T](s.sess, s.model))
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	tp, _ := res.Result.(*T)
+	return tp, nil
+}
 
-	db := s.db.db
-	// 在这里，就是要发起查询，并且处理结果集
-	rows, err := db.QueryContext(ctx, q.SQL, q.Args...)
-	// 这个是查询错误
+// GetAs 和 Selector.Get 一样，但是把结果映射为 R 而不是 T，
+// 用于 SELECT 的列和 T 对不上的场景，例如聚合函数：
+//
+//	stats, err := GetAs[Stats](ctx, NewSelector[User](db).
+//		Select(Avg("Age").As("avg_age"), Max("Age").As("max_age")))
+//
+// R 也可以是 int64 之类的基本类型，这时候只能 SELECT 一列
+func GetAs[R any, T any](ctx context.Context, s *Selector[T]) (*R, error) {
+	q, err := s.Build()
 	if err != nil {
 		return nil, err
 	}
+	m, err := resultModel[R](s.r)
+	if err != nil {
+		return nil, err
+	}
+	res := s.handle(ctx, s.newQueryContext(q, reflect.TypeOf(new(R)), m), getHandler[R](s.sess, m))
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	tp, _ := res.Result.(*R)
+	return tp, nil
+}
 
-	// 你要确认有没有数据
-	if !rows.Next() {
-		// 要不要返回 error？
-		// 返回 error，和 sql 包语义保持一致
-		return nil, ErrNoRows
+// GetMultiAs 参考 GetAs
+func GetMultiAs[R any, T any](ctx context.Context, s *Selector[T]) ([]*R, error) {
+	q, err := s.Build()
+	if err != nil {
+		return nil, err
 	}
+	m, err := resultModel[R](s.r)
+	if err != nil {
+		return nil, err
+	}
+	res := s.handle(ctx, s.newQueryContext(q, reflect.TypeOf([]*R{}), m), getMultiHandler[R](s.sess, m))
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	tps, _ := res.Result.([]*R)
+	return tps, nil
+}
 
-	// if flag {
-	// 	val := valuer.NewReflectValue()
-	// } else {
-	// 	val := valuer.NewUnsafeValue()
-	// }
-	//
-	tp := new(T)
-	val := s.db.creator(s.model, tp)
-	err = val.SetColumns(rows)
+// resultModel 基本类型没有元数据，返回 nil
+func resultModel[R any](r model.Registry) (*model.Model, error) {
+	if isScalar(reflect.TypeOf(new(R)).Elem()) {
+		return nil, nil
+	}
+	return r.Get(new(R))
+}
+
+// getHandler 发起查询，并且把第一行映射为 *T
+// m 为 nil 代表 T 是基本类型，例如 int64、string
+func getHandler[T any](sess Session, m *model.Model) Handler {
+	return func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q := qc.Query
+		// 在这里，就是要发起查询，并且处理结果集
+		rows, err := sess.queryContext(ctx, q.SQL, q.Args...)
+		// 这个是查询错误
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		// 你要确认有没有数据
+		if !rows.Next() {
+			if err = rows.Err(); err != nil {
+				return &QueryResult{Err: err}
+			}
+			// 要不要返回 error？
+			// 返回 error，和 sql 包语义保持一致
+			return &QueryResult{Err: ErrNoRows}
+		}
+
+		tp, err := scanRow[T](ctx, rows, m, sess.getCore().valueCreator())
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		return &QueryResult{Result: tp}
+	}
+}
 
+func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	q, err := s.Build()
+	if err != nil {
+		return nil, err
+	}
+	res := s.handle(ctx, s.newQueryContext(q, reflect.TypeOf([]*T{}), s.model), getMultiHandler[T](s.sess, s.model))
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	tps, _ := res.Result.([]*T)
+	return tps, nil
+}
+
+// getMultiHandler 发起查询，并且把每一行都映射为 *T
+func getMultiHandler[T any](sess Session, m *model.Model) Handler {
+	return func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q := qc.Query
+		rows, err := sess.queryContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		res := make([]*T, 0, 8)
+		creator := sess.getCore().valueCreator()
+		for rows.Next() {
+			// 每一行都创建一个新的 T，复用同一个 valuer 的创建逻辑
+			tp, err := scanRow[T](ctx, rows, m, creator)
+			if err != nil {
+				return &QueryResult{Err: err}
+			}
+			res = append(res, tp)
+		}
+		// 遍历过程中可能出现网络错误之类的，
+		// 这些错误不会在 rows.Next 中返回，要额外检查
+		if err = rows.Err(); err != nil {
+			return &QueryResult{Err: err}
+		}
+		return &QueryResult{Result: res}
+	}
+}
+
+// scanRow 把当前行映射为 *T
+// m 为 nil 的时候直接 Scan，用于 COUNT 之类只有一列的查询
+func scanRow[T any](ctx context.Context, rows *sql.Rows, m *model.Model, creator valuer.Creator) (*T, error) {
+	tp := new(T)
+	if m == nil {
+		if err := rows.Scan(tp); err != nil {
+			return nil, err
+		}
+		return tp, nil
+	}
 	// 接口定义好之后，就两件事，一个是用新接口的方法改造上层，
 	// 一个就是提供不同的实现
-	return tp, err
+	if err := creator(m, tp).SetColumns(rows); err != nil {
+		return nil, err
+	}
+	if err := afterQuery(ctx, tp); err != nil {
+		return nil, err
+	}
+	return tp, nil
 }
 
-func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	panic("implement me")
+// Stream 以迭代器的形式返回结果集，每次只映射一行，
+// 适用于结果集很大，不希望一次性加载到内存里面的场景。
+// 用完之后一定要调用 Iterator.Close
+func (s *Selector[T]) Stream(ctx context.Context) *Iterator[T] {
+	q, err := s.Build()
+	if err != nil {
+		return &Iterator[T]{err: err}
+	}
+	res := s.handle(ctx, s.newQueryContext(q, reflect.TypeOf(&sql.Rows{}), s.model), func(ctx context.Context, qc *QueryContext) *QueryResult {
+		rows, err := s.sess.queryContext(ctx, qc.Query.SQL, qc.Query.Args...)
+		return &QueryResult{
+			Result: rows,
+			Err:    err,
+		}
+	})
+	if res.Err != nil {
+		return &Iterator[T]{err: res.Err}
+	}
+	rows, _ := res.Result.(*sql.Rows)
+	return &Iterator[T]{
+		ctx:     ctx,
+		rows:    rows,
+		model:   s.model,
+		creator: s.valueCreator(),
+	}
 }
 
+func (s *Selector[T]) newQueryContext(q *Query, typ reflect.Type, m *model.Model) *QueryContext {
+	return &QueryContext{
+		Type:        "SELECT",
+		Builder:     s,
+		Model:       s.model,
+		Query:       q,
+		ArgColumns:  s.argCols,
+		Tables:      s.tables,
+		ResultType:  typ,
+		ResultModel: m,
+	}
+}
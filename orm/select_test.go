@@ -102,6 +102,60 @@ func TestSelector_Select(t *testing.T) {
 				SQL: "SELECT COUNT(DISTINCT `first_name`) FROM `test_model`;",
 			},
 		},
+		{
+			name: "count distinct",
+			s: NewSelector[TestModel](db).Select(Count("FirstName").Distinct().As("cnt")),
+			wantQuery: &Query{
+				SQL: "SELECT COUNT(DISTINCT `first_name`) AS `cnt` FROM `test_model`;",
+			},
+		},
+		{
+			name: "math expression",
+			s: NewSelector[TestModel](db).Select(C("Age").Sub(1).Div(2).As("half"), C("Id").Multi(C("Age"))),
+			wantQuery: &Query{
+				SQL: "SELECT (`age` - ?) / ? AS `half`,`id` * `age` FROM `test_model`;",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "function",
+			s: NewSelector[TestModel](db).Select(Lower(C("FirstName")).As("name"),
+				Coalesce(C("LastName"), "unknown"), Length(C("FirstName")).Add(1)),
+			wantQuery: &Query{
+				SQL: "SELECT LOWER(`first_name`) AS `name`,COALESCE(`last_name`,?),LENGTH(`first_name`) + ? FROM `test_model`;",
+				Args: []any{"unknown", 1},
+			},
+		},
+		{
+			name: "function invalid column",
+			s: NewSelector[TestModel](db).Select(Upper(C("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "case when",
+			s: NewSelector[TestModel](db).Select(C("Id"), Case().When(C("Age").Lt(18), "child").
+				When(C("Age").Lt(60), "adult").Else("old").As("stage")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,CASE WHEN `age` < ? THEN ? WHEN `age` < ? THEN ? ELSE ? END AS `stage` FROM `test_model`;",
+				Args: []any{18, "child", 60, "adult", "old"},
+			},
+		},
+		{
+			name: "case without when",
+			s: NewSelector[TestModel](db).Select(Case().Else(1)),
+			wantErr: errs.ErrCaseWithoutWhen,
+		},
+		{
+			name: "invalid function name",
+			s: NewSelector[TestModel](db).Select(Fn("LOWER(`first_name`) --", C("Age"))),
+			wantErr: errs.NewErrInvalidFuncName("LOWER(`first_name`) --"),
+		},
+		{
+			// Excluded 只能在 UPSERT 里面用
+			name: "excluded outside upsert",
+			s: NewSelector[TestModel](db).Where(C("Age").Eq(Excluded("Age"))),
+			wantErr: errs.ErrExcludedOutsideUpsert,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -136,7 +190,7 @@ func TestSelector_Build(t *testing.T) {
 		},
 		{
 			name: "from",
-			builder:  NewSelector[TestModel](db).From("`test_model`"),
+			builder:  NewSelector[TestModel](db).From(TableOf(&TestModel{})),
 			wantQuery: &Query{
 				SQL: "SELECT * FROM `test_model`;",
 				Args: nil,
@@ -144,7 +198,7 @@ func TestSelector_Build(t *testing.T) {
 		},
 		{
 			name: "empty from",
-			builder:  NewSelector[TestModel](db).From(""),
+			builder:  NewSelector[TestModel](db).From(nil),
 			wantQuery: &Query{
 				SQL: "SELECT * FROM `test_model`;",
 				Args: nil,
@@ -152,12 +206,20 @@ func TestSelector_Build(t *testing.T) {
 		},
 		{
 			name: "with db",
-			builder:  NewSelector[TestModel](db).From("`test_db`.`test_model`"),
+			builder:  NewSelector[TestModel](db).From(TableOf(&TestModel{}).In("test_db")),
 			wantQuery: &Query{
 				SQL: "SELECT * FROM `test_db`.`test_model`;",
 				Args: nil,
 			},
 		},
+		{
+			name: "from alias",
+			builder:  NewSelector[TestModel](db).From(TableOf(&TestModel{}).As("t1")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` AS `t1`;",
+				Args: nil,
+			},
+		},
 
 		{
 			name: "empty where",
@@ -229,6 +291,106 @@ func TestSelector_Build(t *testing.T) {
 				Args: []any{18},
 			},
 		},
+		{
+			name: "not eq",
+			builder:  NewSelector[TestModel](db).Where(C("Age").NotEq(18)),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `age` != ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "gt gte lt lte",
+			builder:  NewSelector[TestModel](db).Where(C("Age").Gt(18), C("Age").Gte(19),
+				C("Id").Lt(10), C("Id").Lte(9)),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE (((`age` > ?) AND (`age` >= ?)) AND (`id` < ?)) AND (`id` <= ?);",
+				Args: []any{18, 19, 10, 9},
+			},
+		},
+		{
+			name: "in",
+			builder:  NewSelector[TestModel](db).Where(C("Id").In(1, 2, 3)),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `id` IN (?,?,?);",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			name: "in slice",
+			builder:  NewSelector[TestModel](db).Where(C("Id").In([]int64{1, 2})),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `id` IN (?,?);",
+				Args: []any{int64(1), int64(2)},
+			},
+		},
+		{
+			name: "in bytes",
+			builder:  NewSelector[TestModel](db).Where(C("FirstName").In([]byte("Tom"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `first_name` IN (?);",
+				Args: []any{[]byte("Tom")},
+			},
+		},
+		{
+			name: "in empty",
+			builder:  NewSelector[TestModel](db).Where(C("Age").Eq(18), C("Id").In()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE (`age` = ?) AND (1 = 0);",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "in empty slice",
+			builder:  NewSelector[TestModel](db).Where(C("Id").In([]int{})),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE 1 = 0;",
+			},
+		},
+		{
+			name: "in empty invalid column",
+			builder:  NewSelector[TestModel](db).Where(C("Invalid").In()),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "not in",
+			builder:  NewSelector[TestModel](db).Where(C("Id").NotIn(1, 2)),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `id` NOT IN (?,?);",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "not in empty",
+			builder:  NewSelector[TestModel](db).Where(C("Id").NotIn()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE 1 = 1;",
+			},
+		},
+		{
+			name: "like",
+			builder:  NewSelector[TestModel](db).Where(C("FirstName").Like("Tom%"),
+				C("FirstName").NotLike("%Jerry")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE (`first_name` LIKE ?) AND (`first_name` NOT LIKE ?);",
+				Args: []any{"Tom%", "%Jerry"},
+			},
+		},
+		{
+			name: "between",
+			builder:  NewSelector[TestModel](db).Where(C("Age").Between(18, 20)),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `age` BETWEEN ? AND ?;",
+				Args: []any{18, 20},
+			},
+		},
+		{
+			name: "is null",
+			builder:  NewSelector[TestModel](db).Where(C("LastName").IsNull().Or(C("LastName").IsNotNull())),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE (`last_name` IS NULL) OR (`last_name` IS NOT NULL);",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -243,6 +405,495 @@ func TestSelector_Build(t *testing.T) {
 	}
 }
 
+func TestSelector_GroupByHaving(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct{
+		name string
+		s QueryBuilder
+		wantQuery *Query
+		wantErr error
+	} {
+		{
+			name: "group by",
+			s: NewSelector[TestModel](db).GroupBy(C("Age"), C("FirstName")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `age`,`first_name`;",
+			},
+		},
+		{
+			name: "group by invalid column",
+			s: NewSelector[TestModel](db).GroupBy(C("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "having",
+			s: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(C("Age").Gt(18)),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `age` HAVING `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "having aggregate",
+			s: NewSelector[TestModel](db).Select(C("FirstName"), Avg("Age")).
+				Where(C("Id").Gt(10)).
+				GroupBy(C("FirstName")).
+				Having(Avg("Age").Gt(18), Count("Id").Lt(100)),
+			wantQuery: &Query{
+				SQL: "SELECT `first_name`,AVG(`age`) FROM `test_model` WHERE `id` > ? GROUP BY `first_name` " +
+					"HAVING (AVG(`age`) > ?) AND (COUNT(`id`) < ?);",
+				Args: []any{10, 18, 100},
+			},
+		},
+		{
+			name: "having alias",
+			s: NewSelector[TestModel](db).Select(C("FirstName"), Avg("Age").As("avg_age")).
+				GroupBy(C("FirstName")).
+				Having(C("avg_age").Gt(18)),
+			wantQuery: &Query{
+				SQL: "SELECT `first_name`,AVG(`age`) AS `avg_age` FROM `test_model` GROUP BY `first_name` " +
+					"HAVING `avg_age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "alias in where",
+			s: NewSelector[TestModel](db).Select(Avg("Age").As("avg_age")).
+				Where(C("avg_age").Gt(18)),
+			wantErr: errs.NewErrUnknownField("avg_age"),
+		},
+		{
+			name: "having invalid column",
+			s: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(Avg("Invalid").Gt(18)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "expression in where and having",
+			s: NewSelector[TestModel](db).Select(Lower(C("FirstName")).As("name"), Count("Id").Distinct()).
+				Where(Lower(C("FirstName")).Like("t%"), C("Age").Add(1).Gt(18)).
+				GroupBy(C("FirstName")).
+				Having(Case().When(C("Age").Gt(18), 1).Else(0).Eq(1), Fn("SUM", C("Age")).Div(2).Lt(100)),
+			wantQuery: &Query{
+				SQL: "SELECT LOWER(`first_name`) AS `name`,COUNT(DISTINCT `id`) FROM `test_model` " +
+					"WHERE (LOWER(`first_name`) LIKE ?) AND ((`age` + ?) > ?) GROUP BY `first_name` " +
+					"HAVING (CASE WHEN `age` > ? THEN ? ELSE ? END = ?) AND ((SUM(`age`) / ?) < ?);",
+				Args: []any{"t%", 1, 18, 18, 1, 0, 1, 2, 100},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.s.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSelector_OrderByLimit(t *testing.T) {
+	db := memoryDB(t)
+	sqliteDB := memoryDB(t, DBWithDialect(DialectSQLite))
+	testCases := []struct{
+		name string
+		s QueryBuilder
+		wantQuery *Query
+		wantErr error
+	} {
+		{
+			name: "order by",
+			s: NewSelector[TestModel](db).OrderBy(Asc("Age"), Desc("Id")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `age` ASC,`id` DESC;",
+			},
+		},
+		{
+			name: "order by alias",
+			s: NewSelector[TestModel](db).Select(C("FirstName"), Avg("Age").As("avg_age")).
+				GroupBy(C("FirstName")).OrderBy(Desc("avg_age")),
+			wantQuery: &Query{
+				SQL: "SELECT `first_name`,AVG(`age`) AS `avg_age` FROM `test_model` " +
+					"GROUP BY `first_name` ORDER BY `avg_age` DESC;",
+			},
+		},
+		{
+			name: "order by invalid column",
+			s: NewSelector[TestModel](db).OrderBy(Asc("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "limit offset",
+			s: NewSelector[TestModel](db).Where(C("Age").Gt(18)).
+				OrderBy(Asc("Id")).Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `age` > ? ORDER BY `id` ASC LIMIT ? OFFSET ?;",
+				Args: []any{18, 10, 20},
+			},
+		},
+		{
+			name: "limit",
+			s: NewSelector[TestModel](db).Limit(10),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` LIMIT ?;",
+				Args: []any{10},
+			},
+		},
+		{
+			name: "mysql offset only",
+			s: NewSelector[TestModel](db).Offset(20),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` LIMIT 18446744073709551615 OFFSET ?;",
+				Args: []any{20},
+			},
+		},
+		{
+			name: "sqlite offset only",
+			s: NewSelector[TestModel](sqliteDB).Offset(20),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` LIMIT -1 OFFSET ?;",
+				Args: []any{20},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.s.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSelector_Join(t *testing.T) {
+	db := memoryDB(t)
+	type Order struct {
+		Id int
+		UsingCol1 string
+		UsingCol2 string
+	}
+
+	type OrderDetail struct {
+		OrderId int
+		ItemId int
+		UsingCol1 string
+		UsingCol2 string
+	}
+
+	type Item struct {
+		Id int
+	}
+
+	testCases := []struct{
+		name string
+		s QueryBuilder
+		wantQuery *Query
+		wantErr error
+	} {
+		{
+			name: "specify table",
+			s: NewSelector[Order](db).From(TableOf(&OrderDetail{})),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order_detail`;",
+			},
+		},
+		{
+			name: "join using",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{})
+				t2 := TableOf(&OrderDetail{})
+				return NewSelector[Order](db).
+					From(t1.Join(t2).Using("UsingCol1", "UsingCol2"))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (`order` JOIN `order_detail` USING (`using_col1`,`using_col2`));",
+			},
+		},
+		{
+			name: "join on",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{}).As("t1")
+				t2 := TableOf(&OrderDetail{}).As("t2")
+				return NewSelector[Order](db).
+					From(t1.Join(t2).On(t1.C("Id").Eq(t2.C("OrderId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (`order` AS `t1` JOIN `order_detail` AS `t2` ON `t1`.`id` = `t2`.`order_id`);",
+			},
+		},
+		{
+			name: "join on without alias",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{})
+				t2 := TableOf(&OrderDetail{}).In("shop")
+				return NewSelector[Order](db).
+					Select(t1.C("Id"), t2.C("ItemId")).
+					From(t1.Join(t2).On(t1.C("Id").Eq(t2.C("OrderId")))).
+					Where(t1.C("Id").Eq(1))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `order`.`id`,`shop`.`order_detail`.`item_id` FROM " +
+					"(`order` JOIN `shop`.`order_detail` ON `order`.`id` = `shop`.`order_detail`.`order_id`) " +
+					"WHERE `order`.`id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "left join",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{}).As("t1")
+				t2 := TableOf(&OrderDetail{}).As("t2")
+				return NewSelector[Order](db).
+					From(t1.LeftJoin(t2).On(t1.C("Id").Eq(t2.C("OrderId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (`order` AS `t1` LEFT JOIN `order_detail` AS `t2` ON `t1`.`id` = `t2`.`order_id`);",
+			},
+		},
+		{
+			name: "right join",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{}).As("t1")
+				t2 := TableOf(&OrderDetail{}).As("t2")
+				return NewSelector[Order](db).
+					From(t1.RightJoin(t2).On(t1.C("Id").Eq(t2.C("OrderId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (`order` AS `t1` RIGHT JOIN `order_detail` AS `t2` ON `t1`.`id` = `t2`.`order_id`);",
+			},
+		},
+		{
+			name: "join table",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{}).As("t1")
+				t2 := TableOf(&OrderDetail{}).As("t2")
+				t3 := TableOf(&Item{}).As("t3")
+				j := t1.Join(t2).On(t1.C("Id").Eq(t2.C("OrderId")))
+				return NewSelector[Order](db).
+					Select(t1.C("Id").As("order_id"), t3.C("Id")).
+					From(j.Join(t3).On(t2.C("ItemId").Eq(t3.C("Id")))).
+					Where(t1.C("Id").Eq(1))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `t1`.`id` AS `order_id`,`t3`.`id` FROM " +
+					"((`order` AS `t1` JOIN `order_detail` AS `t2` ON `t1`.`id` = `t2`.`order_id`) " +
+					"JOIN `item` AS `t3` ON `t2`.`item_id` = `t3`.`id`) WHERE `t1`.`id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "table join",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{}).As("t1")
+				t2 := TableOf(&OrderDetail{}).As("t2")
+				t3 := TableOf(&Item{}).As("t3")
+				j := t2.Join(t3).On(t2.C("ItemId").Eq(t3.C("Id")))
+				return NewSelector[Order](db).
+					From(t1.Join(j).On(t1.C("Id").Eq(t2.C("OrderId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM " +
+					"(`order` AS `t1` JOIN (`order_detail` AS `t2` JOIN `item` AS `t3` ON `t2`.`item_id` = `t3`.`id`) " +
+					"ON `t1`.`id` = `t2`.`order_id`);",
+			},
+		},
+		{
+			name: "invalid column",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{}).As("t1")
+				t2 := TableOf(&OrderDetail{}).As("t2")
+				return NewSelector[Order](db).
+					From(t1.Join(t2).On(t1.C("Invalid").Eq(t2.C("OrderId"))))
+			}(),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "invalid using column",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{})
+				t2 := TableOf(&OrderDetail{})
+				return NewSelector[Order](db).
+					From(t1.Join(t2).Using("Invalid"))
+			}(),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.s.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSelector_Subquery(t *testing.T) {
+	db := memoryDB(t)
+	type Order struct {
+		Id int
+		UsingCol1 string
+		UsingCol2 string
+	}
+
+	type OrderDetail struct {
+		OrderId int
+		ItemId int
+		UsingCol1 string
+		UsingCol2 string
+	}
+
+	testCases := []struct{
+		name string
+		s QueryBuilder
+		wantQuery *Query
+		wantErr error
+	} {
+		{
+			name: "from",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Where(C("ItemId").Eq(1)).AsSubquery("sub")
+				return NewSelector[Order](db).From(sub).Where(sub.C("OrderId").Eq(2))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (SELECT * FROM `order_detail` WHERE `item_id` = ?) AS `sub` WHERE `sub`.`order_id` = ?;",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "columns alias",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId").As("oid")).AsSubquery("sub")
+				return NewSelector[Order](db).Select(sub.C("oid")).From(sub)
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `sub`.`oid` FROM (SELECT `order_id` AS `oid` FROM `order_detail`) AS `sub`;",
+			},
+		},
+		{
+			name: "invalid column",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId")).AsSubquery("sub")
+				return NewSelector[Order](db).Select(sub.C("ItemId")).From(sub)
+			}(),
+			wantErr: errs.NewErrUnknownField("ItemId"),
+		},
+		{
+			name: "join",
+			s: func() QueryBuilder {
+				t1 := TableOf(&Order{})
+				sub := NewSelector[OrderDetail](db).Where(C("ItemId").Eq(1)).AsSubquery("sub")
+				return NewSelector[Order](db).
+					From(t1.Join(sub).On(t1.C("Id").Eq(sub.C("OrderId")))).
+					Where(C("Id").Eq(2))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (`order` JOIN (SELECT * FROM `order_detail` WHERE `item_id` = ?) AS `sub` " +
+					"ON `order`.`id` = `sub`.`order_id`) WHERE `id` = ?;",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "in",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId")).
+					Where(C("ItemId").Eq(1)).AsSubquery("sub")
+				return NewSelector[Order](db).Where(C("Id").Eq(2), C("Id").InQuery(sub))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` WHERE (`id` = ?) AND (`id` IN (SELECT `order_id` FROM `order_detail` WHERE `item_id` = ?));",
+				Args: []any{2, 1},
+			},
+		},
+		{
+			name: "exists",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Where(C("ItemId").Eq(1)).AsSubquery("sub")
+				return NewSelector[Order](db).Where(Exists(sub))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` WHERE  EXISTS (SELECT * FROM `order_detail` WHERE `item_id` = ?);",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "not exists",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Where(C("ItemId").Eq(1)).AsSubquery("sub")
+				return NewSelector[Order](db).Where(NotExists(sub))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` WHERE  NOT EXISTS (SELECT * FROM `order_detail` WHERE `item_id` = ?);",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "all",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId")).AsSubquery("sub")
+				return NewSelector[Order](db).Where(C("Id").Eq(All(sub)))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` WHERE `id` = ALL (SELECT `order_id` FROM `order_detail`);",
+			},
+		},
+		{
+			name: "any",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId")).
+					Where(C("ItemId").Eq(1)).AsSubquery("sub")
+				return NewSelector[Order](db).Where(C("Id").Eq(Any(sub)), C("Id").Eq(3))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` WHERE (`id` = ANY (SELECT `order_id` FROM `order_detail` WHERE `item_id` = ?)) AND (`id` = ?);",
+				Args: []any{1, 3},
+			},
+		},
+		{
+			name: "select",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(Max("ItemId")).
+					Where(C("ItemId").Eq(1)).AsSubquery("max_item")
+				return NewSelector[Order](db).Select(C("Id"), sub).Where(C("Id").Eq(2))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,(SELECT MAX(`item_id`) FROM `order_detail` WHERE `item_id` = ?) AS `max_item` FROM `order` WHERE `id` = ?;",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "invalid subquery",
+			s: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Where(C("Invalid").Eq(1)).AsSubquery("sub")
+				return NewSelector[Order](db).Where(Exists(sub))
+			}(),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.s.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
 type TestModel struct {
 	Id        int64
 	// ""
@@ -337,6 +988,187 @@ func TestSelector_Get(t *testing.T) {
 	}
 }
 
+func TestSelector_GetMulti(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	// 对应于 query error
+	mock.ExpectQuery("SELECT .*").WillReturnError(errors.New("query error"))
+
+	// 对应于 no rows
+	rows := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	// data
+	rows = sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	rows.AddRow("1", "Tom", "18", "Jerry")
+	rows.AddRow("2", "Tom", "19", "Jerry")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	// rows error
+	rows = sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	rows.AddRow("1", "Tom", "18", "Jerry")
+	rows.AddRow("2", "Tom", "19", "Jerry")
+	rows.RowError(1, errors.New("rows error"))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	// unknown column
+	rows = sqlmock.NewRows([]string{"id", "invalid"})
+	rows.AddRow("1", "Tom")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	testCases := []struct{
+		name string
+		s *Selector[TestModel]
+
+		wantErr error
+		wantRes []*TestModel
+	} {
+		{
+			name: "invalid query",
+			s: NewSelector[TestModel](db).Where(C("XXX").Eq(1)),
+			wantErr: errs.NewErrUnknownField("XXX"),
+		},
+		{
+			name: "query error",
+			s: NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+			wantErr: errors.New("query error"),
+		},
+		{
+			name: "no rows",
+			s: NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+			wantRes: []*TestModel{},
+		},
+		{
+			name: "data",
+			s: NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+			wantRes: []*TestModel{
+				{
+					Id:        1,
+					FirstName: "Tom",
+					Age:       18,
+					LastName:  &sql.NullString{Valid: true, String: "Jerry"},
+				},
+				{
+					Id:        2,
+					FirstName: "Tom",
+					Age:       19,
+					LastName:  &sql.NullString{Valid: true, String: "Jerry"},
+				},
+			},
+		},
+		{
+			name: "rows error",
+			s: NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+			wantErr: errors.New("rows error"),
+		},
+		{
+			name: "unknown column",
+			s: NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+			wantErr: errs.NewErrUnknownColumn("invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.s.GetMulti(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestSelector_Stream(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	// 对应于 query error
+	mock.ExpectQuery("SELECT .*").WillReturnError(errors.New("query error"))
+
+	// data
+	rows := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	rows.AddRow("1", "Tom", "18", "Jerry")
+	rows.AddRow("2", "Tom", "19", "Jerry")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	// rows error
+	rows = sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	rows.AddRow("1", "Tom", "18", "Jerry")
+	rows.AddRow("2", "Tom", "19", "Jerry")
+	rows.RowError(1, errors.New("rows error"))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+	testCases := []struct{
+		name string
+		s *Selector[TestModel]
+
+		wantErr error
+		wantRes []*TestModel
+	} {
+		{
+			name: "invalid query",
+			s: NewSelector[TestModel](db).Where(C("XXX").Eq(1)),
+			wantErr: errs.NewErrUnknownField("XXX"),
+		},
+		{
+			name: "query error",
+			s: NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+			wantErr: errors.New("query error"),
+		},
+		{
+			name: "data",
+			s: NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+			wantRes: []*TestModel{
+				{
+					Id:        1,
+					FirstName: "Tom",
+					Age:       18,
+					LastName:  &sql.NullString{Valid: true, String: "Jerry"},
+				},
+				{
+					Id:        2,
+					FirstName: "Tom",
+					Age:       19,
+					LastName:  &sql.NullString{Valid: true, String: "Jerry"},
+				},
+			},
+		},
+		{
+			name: "rows error",
+			s: NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+			wantErr: errors.New("rows error"),
+			wantRes: []*TestModel{
+				{
+					Id:        1,
+					FirstName: "Tom",
+					Age:       18,
+					LastName:  &sql.NullString{Valid: true, String: "Jerry"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			it := tc.s.Stream(context.Background())
+			var res []*TestModel
+			for it.Next() {
+				res = append(res, it.Item())
+			}
+			assert.Equal(t, tc.wantErr, it.Err())
+			assert.Equal(t, tc.wantRes, res)
+			assert.NoError(t, it.Close())
+		})
+	}
+}
+
 func memoryDB(t *testing.T, opts...DBOption) *DB {
 	db, err := Open("sqlite3",
 		"file:test.db?cache=shared&mode=memory",
@@ -391,4 +1223,190 @@ func BenchmarkQuerier_Get(b *testing.B) {
 			}
 		}
 	})
-}
\ No newline at end of file
+}
+func TestGetAs(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	// 聚合函数
+	rows := sqlmock.NewRows([]string{"avg_age", "max_age"})
+	rows.AddRow("18.5", "20")
+	mock.ExpectQuery("SELECT AVG\\(`age`\\) AS `avg_age`,MAX\\(`age`\\) AS `max_age` FROM `test_model`;").
+		WillReturnRows(rows)
+	stats, err := GetAs[AgeStats](context.Background(), NewSelector[TestModel](db).
+		Select(Avg("Age").As("avg_age"), Max("Age").As("max_age")))
+	require.NoError(t, err)
+	assert.Equal(t, &AgeStats{AvgAge: 18.5, MaxAge: 20}, stats)
+
+	// 基本类型
+	rows = sqlmock.NewRows([]string{"COUNT(`id`)"})
+	rows.AddRow(10)
+	mock.ExpectQuery("SELECT COUNT.*").WillReturnRows(rows)
+	cnt, err := GetAs[int64](context.Background(), NewSelector[TestModel](db).Select(Count("Id")))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), *cnt)
+
+	// 分组
+	rows = sqlmock.NewRows([]string{"age", "cnt"})
+	rows.AddRow(18, 2)
+	rows.AddRow(19, 3)
+	mock.ExpectQuery("SELECT `age`,COUNT\\(`id`\\) AS `cnt` FROM `test_model` GROUP BY `age`;").
+		WillReturnRows(rows)
+	groups, err := GetMultiAs[AgeCount](context.Background(), NewSelector[TestModel](db).
+		Select(C("Age"), Count("Id").As("cnt")).GroupBy(C("Age")))
+	require.NoError(t, err)
+	assert.Equal(t, []*AgeCount{{Age: 18, Cnt: 2}, {Age: 19, Cnt: 3}}, groups)
+
+	// 构造 SQL 失败
+	_, err = GetAs[AgeStats](context.Background(), NewSelector[TestModel](db).Where(C("XXX").Eq(1)))
+	assert.Equal(t, errs.NewErrUnknownField("XXX"), err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_IgnoreUnknownColumns(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBIgnoreUnknownColumns())
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"id", "first_name", "avg_age"})
+	rows.AddRow("1", "Tom", "18.5")
+	rows.AddRow("2", "Jerry", "19.5")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	res, err := NewSelector[TestModel](db).
+		Select(C("Id"), C("FirstName"), Avg("Age").As("avg_age")).
+		GroupBy(C("Id"), C("FirstName")).GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{{Id: 1, FirstName: "Tom"}, {Id: 2, FirstName: "Jerry"}}, res)
+
+	// 使用反射也一样
+	db, err = OpenDB(mockDB, DBUseReflect(), DBIgnoreUnknownColumns())
+	require.NoError(t, err)
+	rows = sqlmock.NewRows([]string{"id", "avg_age"})
+	rows.AddRow("1", "18.5")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	tm, err := NewSelector[TestModel](db).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1}, tm)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type AgeStats struct {
+	AvgAge float64
+	MaxAge int8
+}
+
+type AgeCount struct {
+	Age int8
+	Cnt int64
+}
+
+func TestSelector_EmbeddedModel(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "unsafe",
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBUseReflect()},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := Open("sqlite3",
+				fmt.Sprintf("file:embedded_%s.db?cache=shared&mode=memory", tc.name),
+				append(tc.opts, DBWithDialect(DialectSQLite))...)
+			require.NoError(t, err)
+			_, err = db.db.Exec("CREATE TABLE `embedded_model`(`id` INTEGER PRIMARY KEY, " +
+				"`created_at` INTEGER, `age` INTEGER, `nick_name` TEXT)")
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			age := int8(18)
+			err = NewInserter[EmbeddedModel](db).Values(&EmbeddedModel{
+				BaseModel: BaseModel{Id: 1, CreatedAt: 1000},
+				Age:       &age,
+				NickName:  sql.NullString{String: "Tom", Valid: true},
+				Password:  "123456",
+			}, &EmbeddedModel{
+				BaseModel: BaseModel{Id: 2, CreatedAt: 2000},
+			}).Exec(ctx).Err()
+			require.NoError(t, err)
+
+			res, err := NewSelector[EmbeddedModel](db).
+				Where(C("CreatedAt").Gt(0)).OrderBy(Asc("Id")).GetMulti(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []*EmbeddedModel{
+				{
+					BaseModel: BaseModel{Id: 1, CreatedAt: 1000},
+					Age:       &age,
+					NickName:  sql.NullString{String: "Tom", Valid: true},
+				},
+				{
+					BaseModel: BaseModel{Id: 2, CreatedAt: 2000},
+				},
+			}, res)
+		})
+	}
+}
+
+type BaseModel struct {
+	Id        int64
+	CreatedAt int64
+}
+
+type EmbeddedModel struct {
+	BaseModel
+	Age      *int8
+	NickName sql.NullString
+	// 不会被映射成列
+	Password string `orm:"-"`
+}
+
+func TestSelector_Expression_SQLite(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(DialectSQLite))
+	_, err := db.db.Exec("CREATE TABLE IF NOT EXISTS `expression_model`(`id` INTEGER PRIMARY KEY, `name` TEXT, `age` INTEGER)")
+	require.NoError(t, err)
+	_, err = db.db.Exec("INSERT INTO `expression_model` VALUES (1, 'Tom', 10),(2, 'Jerry', 20),(3, 'TOM', 30)")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	type result struct {
+		Name  string
+		Stage string
+		Half  float64
+	}
+	res, err := GetMultiAs[result](ctx, NewSelector[ExpressionModel](db).
+		Select(Lower(C("Name")).As("name"),
+			Case().When(C("Age").Lt(18), "child").Else("adult").As("stage"),
+			C("Age").Div(2.0).As("half")).
+		Where(Upper(C("Name")).Eq("TOM")))
+	require.NoError(t, err)
+	assert.Equal(t, []*result{
+		{Name: "tom", Stage: "child", Half: 5},
+		{Name: "tom", Stage: "adult", Half: 15},
+	}, res)
+
+	cnt, err := GetAs[int](ctx, NewSelector[ExpressionModel](db).
+		Select(Count("Name").Distinct()))
+	require.NoError(t, err)
+	assert.Equal(t, 3, *cnt)
+
+	require.NoError(t, NewUpdater[ExpressionModel](db).
+		Set(Assign("Name", Upper(C("Name"))), Assign("Age", C("Age").Sub(1))).
+		Where(C("Id").Eq(2)).Exec(ctx).Err())
+	m, err := NewSelector[ExpressionModel](db).Where(C("Id").Eq(2)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &ExpressionModel{Id: 2, Name: "JERRY", Age: 19}, m)
+}
+
+type ExpressionModel struct {
+	Id   int64
+	Name string
+	Age  int
+}
@@ -0,0 +1,37 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/valuer"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+)
+
+// Session 代表一个可以执行查询的会话，DB 和 Tx 都实现了这个接口
+// 所有的 builder 都是基于 Session 构造的，所以既可以在事务里面执行，
+// 也可以在事务外面执行
+type Session interface {
+	getCore() core
+	queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	execContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// core 是 DB 和 Tx 共享的部分
+type core struct {
+	r       model.Registry
+	creator valuer.Creator
+	dialect Dialect
+	mdls    []Middleware
+	// ignoreUnknownColumns 为 true 的时候，结果集里面多出来的列会被丢弃
+	ignoreUnknownColumns bool
+	// tx 不为 nil 代表这是 Tx 的 core
+	tx *Tx
+}
+
+// valueCreator 根据配置决定结果集里面有未知列的时候，是报错还是忽略
+func (c core) valueCreator() valuer.Creator {
+	if c.ignoreUnknownColumns {
+		return valuer.IgnoreUnknownColumns(c.creator)
+	}
+	return c.creator
+}
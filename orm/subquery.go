@@ -0,0 +1,93 @@
+package orm
+
+// Subquery 代表子查询，通过 Selector.AsSubquery 构造。
+// 它可以用在 FROM 后面，也可以用在 WHERE 和 SELECT 里面
+type Subquery struct {
+	s     subqueryBuilder
+	alias string
+	// table 是子查询本身的 FROM 部分，用于解析子查询的列
+	table TableReference
+	// columns 是子查询 SELECT 的列
+	columns []Selectable
+}
+
+// subqueryBuilder 构造子查询，返回的 SQL 里面的占位符还没有按照方言改写
+type subqueryBuilder interface {
+	build() (*Query, error)
+	// argColumns 和 build 返回的参数一一对应
+	argColumns() []string
+	// tableNames 是子查询用到的表
+	tableNames() []string
+}
+
+func (s Subquery) tableAlias() string {
+	return s.alias
+}
+
+func (s Subquery) expr() {}
+
+func (s Subquery) selectable() {}
+
+// C 引用子查询的列，name 可以是字段名，也可以是子查询中列的别名
+// sub := NewSelector[OrderDetail](db).AsSubquery("sub")
+// sub.C("OrderId")
+func (s Subquery) C(name string) Column {
+	return Column{
+		name:  name,
+		table: s,
+	}
+}
+
+func (s Subquery) Join(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  s,
+		right: right,
+		typ:   "JOIN",
+	}
+}
+
+func (s Subquery) LeftJoin(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  s,
+		right: right,
+		typ:   "LEFT JOIN",
+	}
+}
+
+func (s Subquery) RightJoin(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  s,
+		right: right,
+		typ:   "RIGHT JOIN",
+	}
+}
+
+// SubqueryExpr 代表 ANY、ALL、SOME 修饰的子查询
+// C("Id").Eq(Any(sub))
+type SubqueryExpr struct {
+	s    Subquery
+	pred string
+}
+
+func (SubqueryExpr) expr() {}
+
+func Any(sub Subquery) SubqueryExpr {
+	return SubqueryExpr{
+		s:    sub,
+		pred: "ANY",
+	}
+}
+
+func All(sub Subquery) SubqueryExpr {
+	return SubqueryExpr{
+		s:    sub,
+		pred: "ALL",
+	}
+}
+
+func Some(sub Subquery) SubqueryExpr {
+	return SubqueryExpr{
+		s:    sub,
+		pred: "SOME",
+	}
+}
@@ -0,0 +1,141 @@
+package orm
+
+// TableReference 代表 FROM 后面可以跟的东西，
+// 目前有普通的表 Table 和 JOIN 查询 Join
+type TableReference interface {
+	tableAlias() string
+}
+
+// Table 代表一张普通的表
+type Table struct {
+	entity any
+	alias  string
+	// db 是表所在的数据库，PostgreSQL 里面是 schema
+	db string
+}
+
+// TableOf 用结构体来指定表，表名、列名都从 entity 的元数据里面解析
+// TableOf(&Order{})
+func TableOf(entity any) Table {
+	return Table{
+		entity: entity,
+	}
+}
+
+func (t Table) tableAlias() string {
+	return t.alias
+}
+
+func (t Table) As(alias string) Table {
+	t.alias = alias
+	return t
+}
+
+// In 指定表所在的数据库
+// TableOf(&Order{}).In("test_db") => `test_db`.`order`
+func (t Table) In(db string) Table {
+	t.db = db
+	return t
+}
+
+// C 引用这张表上的列
+// t1 := TableOf(&Order{}).As("t1")
+// t1.C("Id")
+func (t Table) C(name string) Column {
+	return Column{
+		name:  name,
+		table: t,
+	}
+}
+
+func (t Table) Join(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  t,
+		right: right,
+		typ:   "JOIN",
+	}
+}
+
+func (t Table) LeftJoin(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  t,
+		right: right,
+		typ:   "LEFT JOIN",
+	}
+}
+
+func (t Table) RightJoin(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  t,
+		right: right,
+		typ:   "RIGHT JOIN",
+	}
+}
+
+// Join 代表 JOIN 查询，一般通过 JoinBuilder 来构造
+// t1.Join(t2).On(t1.C("Id").Eq(t2.C("OrderId")))
+type Join struct {
+	left  TableReference
+	right TableReference
+	typ   string
+	on    []Predicate
+	using []string
+}
+
+// tableAlias JOIN 查询本身是没有别名的
+func (j Join) tableAlias() string {
+	return ""
+}
+
+// Join 可以继续 JOIN 别的表
+// t1.Join(t2).On(...).Join(t3).On(...)
+func (j Join) Join(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  j,
+		right: right,
+		typ:   "JOIN",
+	}
+}
+
+func (j Join) LeftJoin(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  j,
+		right: right,
+		typ:   "LEFT JOIN",
+	}
+}
+
+func (j Join) RightJoin(right TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  j,
+		right: right,
+		typ:   "RIGHT JOIN",
+	}
+}
+
+// JoinBuilder 是构造 Join 的中间结构，
+// 必须调用 On 或者 Using 才能得到 Join
+type JoinBuilder struct {
+	left  TableReference
+	right TableReference
+	typ   string
+}
+
+func (j *JoinBuilder) On(ps ...Predicate) Join {
+	return Join{
+		left:  j.left,
+		right: j.right,
+		typ:   j.typ,
+		on:    ps,
+	}
+}
+
+// Using 指定 USING 的列，传入的是字段名
+func (j *JoinBuilder) Using(cols ...string) Join {
+	return Join{
+		left:  j.left,
+		right: j.right,
+		typ:   j.typ,
+		using: cols,
+	}
+}
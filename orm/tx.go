@@ -0,0 +1,57 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+var _ Session = &Tx{}
+
+// Tx 是 sql.Tx 的装饰器
+type Tx struct {
+	core
+	tx *sql.Tx
+
+	mutex       sync.Mutex
+	afterCommit []func()
+}
+
+func (t *Tx) getCore() core {
+	return t.core
+}
+
+func (t *Tx) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+func (t *Tx) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+// AfterCommit 注册一个在事务提交成功之后执行的回调，回滚的时候不会执行。
+// 例如缓存的 middleware 在提交之后要再删一次缓存，
+// 因为提交之前别的查询依旧会读到旧数据，并且把旧数据放回缓存
+func (t *Tx) AfterCommit(fn func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.afterCommit = append(t.afterCommit, fn)
+}
+
+func (t *Tx) Commit() error {
+	if err := t.tx.Commit(); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	fns := t.afterCommit
+	t.afterCommit = nil
+	t.mutex.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	return nil
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
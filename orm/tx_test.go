@@ -0,0 +1,159 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTx_Selector(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	rows.AddRow("1", "Tom", "18", "Jerry")
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{})
+	require.NoError(t, err)
+	res, err := NewSelector[TestModel](tx).Where(C("Id").Eq(1)).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{
+		Id:        1,
+		FirstName: "Tom",
+		Age:       18,
+		LastName:  &sql.NullString{Valid: true, String: "Jerry"},
+	}, res)
+	affected, err := NewInserter[TestModel](tx).Values(&TestModel{Id: 2}).
+		Exec(context.Background()).RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	require.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_AfterCommit(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 回滚的时候不会执行
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	var cnt int
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	tx.AfterCommit(func() { cnt++ })
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, 0, cnt)
+
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	tx.AfterCommit(func() { cnt++ })
+	tx.AfterCommit(func() { cnt++ })
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, 2, cnt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDB_DoTx(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+		fn   func(ctx context.Context, tx *Tx) error
+
+		wantErr   error
+		wantPanic any
+	}{
+		{
+			name: "begin error",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("begin error"))
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				return nil
+			},
+			wantErr: errors.New("begin error"),
+		},
+		{
+			name: "commit",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				return NewUpdater[TestModel](tx).Update(&TestModel{Age: 18}).
+					Where(C("Id").Eq(1)).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				return errors.New("biz error")
+			},
+			wantErr: errors.New("biz error"),
+		},
+		{
+			name: "rollback error",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback().WillReturnError(errors.New("rollback error"))
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				return errors.New("biz error")
+			},
+			wantErr: fmt.Errorf("orm: 回滚事务失败, 业务错误 %w, 回滚错误 %s",
+				errors.New("biz error"), "rollback error"),
+		},
+		{
+			name: "panic",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(ctx context.Context, tx *Tx) error {
+				panic("biz panic")
+			},
+			wantPanic: "biz panic",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := OpenDB(mockDB)
+			require.NoError(t, err)
+			tc.mock(mock)
+
+			if tc.wantPanic != nil {
+				assert.PanicsWithValue(t, tc.wantPanic, func() {
+					_ = db.DoTx(context.Background(), tc.fn, nil)
+				})
+			} else {
+				err = db.DoTx(context.Background(), tc.fn, nil)
+				assert.Equal(t, tc.wantErr, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -2,6 +2,8 @@ package orm
 
 import (
 	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/model"
+	"reflect"
 )
 
 // Querier 用于 SELECT 语句
@@ -30,3 +32,30 @@ type Query struct {
 	Args []any
 }
 
+// QueryContext 代表一次查询的上下文
+type QueryContext struct {
+	// Type 是语句的类型，SELECT, INSERT, UPDATE 或者 DELETE
+	Type string
+	// Builder 是构造这个查询的 builder
+	Builder QueryBuilder
+	// Model 是查询对应的元数据
+	Model *model.Model
+	// Query 是构造好的查询，在 BeforeXXX 钩子里面还是 nil
+	Query *Query
+	// ArgColumns 和 Query.Args 一一对应，是每个参数绑定的列名，
+	// 例如 INSERT 的列、UPDATE 被赋值的列、WHERE 里面比较的列，
+	// 不知道的时候是空字符串。日志之类的 middleware 可以据此脱敏
+	ArgColumns []string
+	// Tables 是 SELECT 用到的所有表，包括 JOIN 和子查询里面的表，
+	// DELETE 的时候是 From 指定的表和子查询里面的表。
+	// 原生查询不知道用到了哪些表，所以是 nil
+	Tables []string
+	// Tx 不为 nil 代表这个查询是在事务里面执行的
+	Tx *Tx
+	// ResultType 是 QueryResult.Result 的类型，只有 SELECT 才有
+	// 例如 Selector.Get 是 *T，缓存之类的 middleware 可以据此反序列化
+	ResultType reflect.Type
+	// ResultModel 是 ResultType 里面的 T 对应的元数据，
+	// 例如 GetAs[R] 是 R 的元数据，R 是基本类型的时候是 nil
+	ResultModel *model.Model
+}
@@ -0,0 +1,194 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"reflect"
+)
+
+// Updater 用于构造 UPDATE 语句
+type Updater[T any] struct {
+	builder
+	sess      Session
+	val       *T
+	assigns   []Assignable
+	where     []Predicate
+	returning []string
+}
+
+func NewUpdater[T any](sess Session) *Updater[T] {
+	return &Updater[T]{
+		builder: newBuilder(sess),
+		sess:    sess,
+	}
+}
+
+// Update 指定要更新的数据。
+// 如果没有调用 Set，那么会更新 val 里面所有的非零值字段
+func (u *Updater[T]) Update(val *T) *Updater[T] {
+	u.val = val
+	return u
+}
+
+// Set 指定要更新的列
+// Set(C("FirstName")) 代表使用 Update 传入的数据中 FirstName 的值
+// Set(Assign("Age", C("Age").Add(1))) 代表 `age`=`age` + 1
+func (u *Updater[T]) Set(assigns ...Assignable) *Updater[T] {
+	u.assigns = assigns
+	return u
+}
+
+func (u *Updater[T]) Where(ps ...Predicate) *Updater[T] {
+	u.where = ps
+	return u
+}
+
+// Returning 指定 RETURNING 的列，传入的是字段名
+// MySQL 不支持 RETURNING
+func (u *Updater[T]) Returning(cols ...string) *Updater[T] {
+	u.returning = cols
+	return u
+}
+
+func (u *Updater[T]) Build() (*Query, error) {
+	// Build 可能被调用多次，例如 Exec 之前先 Build 一次打印 SQL
+	u.sb.Reset()
+	u.args = nil
+	u.argCols = nil
+	var err error
+	u.model, err = u.r.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	u.sb.WriteString("UPDATE ")
+	u.quote(u.model.TableName)
+	u.sb.WriteString(" SET ")
+
+	if len(u.assigns) > 0 {
+		err = u.buildAssigns()
+	} else {
+		err = u.buildNonZeroFields()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(u.where) > 0 {
+		u.sb.WriteString(" WHERE ")
+		if err = u.buildPredicates(u.where); err != nil {
+			return nil, err
+		}
+	}
+	if len(u.returning) > 0 {
+		if err = u.dialect.buildReturning(&u.builder, u.returning); err != nil {
+			return nil, err
+		}
+	}
+	u.sb.WriteByte(';')
+	return u.buildQuery(), nil
+}
+
+func (u *Updater[T]) buildAssigns() error {
+	for idx, assign := range u.assigns {
+		if idx > 0 {
+			u.sb.WriteByte(',')
+		}
+		switch a := assign.(type) {
+		case Assignment:
+			if err := u.buildColumn(a.col); err != nil {
+				return err
+			}
+			u.sb.WriteByte('=')
+			if err := u.buildAssignValue(a); err != nil {
+				return err
+			}
+		case Column:
+			// 使用 Update 传入的数据里面对应字段的值
+			if u.val == nil {
+				return errs.ErrNoUpdatedColumns
+			}
+			if err := u.buildColumn(a.name); err != nil {
+				return err
+			}
+			arg, err := u.creator(u.model, u.val).Field(a.name)
+			if err != nil {
+				return err
+			}
+			u.sb.WriteString("=?")
+			u.addColArg(u.model.FieldMap[a.name].ColName, arg)
+		default:
+			return errs.NewErrUnsupportedAssignable(assign)
+		}
+	}
+	return nil
+}
+
+// buildNonZeroFields 用 val 里面的非零值字段来构造 SET 部分
+func (u *Updater[T]) buildNonZeroFields() error {
+	if u.val == nil {
+		return errs.ErrNoUpdatedColumns
+	}
+	val := u.creator(u.model, u.val)
+	cnt := 0
+	for _, fd := range u.model.Fields {
+		arg, err := val.Field(fd.GoName)
+		if err != nil {
+			return err
+		}
+		if reflect.ValueOf(arg).IsZero() {
+			continue
+		}
+		if cnt > 0 {
+			u.sb.WriteByte(',')
+		}
+		u.quote(fd.ColName)
+		u.sb.WriteString("=?")
+		u.addColArg(fd.ColName, arg)
+		cnt++
+	}
+	if cnt == 0 {
+		return errs.ErrNoUpdatedColumns
+	}
+	return nil
+}
+
+func (u *Updater[T]) Exec(ctx context.Context) Result {
+	if err := u.beforeUpdate(ctx); err != nil {
+		return Result{
+			err: err,
+		}
+	}
+	q, err := u.Build()
+	if err != nil {
+		return Result{
+			err: err,
+		}
+	}
+	return exec(ctx, u.sess, &QueryContext{
+		Type:       "UPDATE",
+		Builder:    u,
+		Model:      u.model,
+		Query:      q,
+		ArgColumns: u.argCols,
+	})
+}
+
+// beforeUpdate 如果 Update 传入的数据实现了 BeforeUpdateHook，那么调用它
+func (u *Updater[T]) beforeUpdate(ctx context.Context) error {
+	if u.val == nil {
+		return nil
+	}
+	hook, ok := any(u.val).(BeforeUpdateHook)
+	if !ok {
+		return nil
+	}
+	m, err := u.r.Get(new(T))
+	if err != nil {
+		return err
+	}
+	return hook.BeforeUpdate(ctx, &QueryContext{
+		Type:    "UPDATE",
+		Builder: u,
+		Model:   m,
+	})
+}
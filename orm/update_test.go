@@ -0,0 +1,194 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUpdater_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name string
+		u    QueryBuilder
+
+		wantErr   error
+		wantQuery *Query
+	}{
+		{
+			name:    "no columns",
+			u:       NewUpdater[TestModel](db),
+			wantErr: errs.ErrNoUpdatedColumns,
+		},
+		{
+			name:    "zero value",
+			u:       NewUpdater[TestModel](db).Update(&TestModel{}),
+			wantErr: errs.ErrNoUpdatedColumns,
+		},
+		{
+			name:    "excluded outside upsert",
+			u:       NewUpdater[TestModel](db).Set(Assign("Age", Excluded("Age"))),
+			wantErr: errs.ErrExcludedOutsideUpsert,
+		},
+		{
+			name: "non-zero fields",
+			u: NewUpdater[TestModel](db).Update(&TestModel{
+				Id:        12,
+				FirstName: "Tom",
+			}),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `id`=?,`first_name`=?;",
+				Args: []any{int64(12), "Tom"},
+			},
+		},
+		{
+			name: "set columns",
+			u: NewUpdater[TestModel](db).Update(&TestModel{
+				Id:        12,
+				FirstName: "Tom",
+				Age:       18,
+				LastName:  &sql.NullString{String: "Jerry", Valid: true},
+			}).Set(C("FirstName"), C("Age")),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=?,`age`=?;",
+				Args: []any{"Tom", int8(18)},
+			},
+		},
+		{
+			name: "set expression",
+			u: NewUpdater[TestModel](db).Set(Assign("Age", C("Age").Sub(1).Multi(2)),
+				Assign("FirstName", Upper(C("FirstName"))),
+				Assign("LastName", Case().When(C("Age").Gt(18), "adult").Else(C("LastName")))),
+			wantQuery: &Query{
+				SQL: "UPDATE `test_model` SET `age`=(`age` - ?) * ?,`first_name`=UPPER(`first_name`)," +
+					"`last_name`=CASE WHEN `age` > ? THEN ? ELSE `last_name` END;",
+				Args: []any{1, 2, 18, "adult"},
+			},
+		},
+		{
+			name:    "set column without value",
+			u:       NewUpdater[TestModel](db).Set(C("FirstName")),
+			wantErr: errs.ErrNoUpdatedColumns,
+		},
+		{
+			name: "assignment",
+			u: NewUpdater[TestModel](db).Update(&TestModel{
+				Id:        12,
+				FirstName: "Tom",
+			}).Set(C("FirstName"), Assign("Age", 18)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=?,`age`=?;",
+				Args: []any{"Tom", 18},
+			},
+		},
+		{
+			name: "invalid column",
+			u: NewUpdater[TestModel](db).Update(&TestModel{}).
+				Set(Assign("Invalid", 18)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "where",
+			u: NewUpdater[TestModel](db).Update(&TestModel{
+				FirstName: "Tom",
+			}).Where(C("Id").Eq(12)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=? WHERE `id` = ?;",
+				Args: []any{"Tom", 12},
+			},
+		},
+		{
+			name: "math expression",
+			u: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Add(1))).
+				Where(C("Id").Eq(12)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=`age` + ? WHERE `id` = ?;",
+				Args: []any{1, 12},
+			},
+		},
+		{
+			name: "nested math expression",
+			u: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Add(1).Multi(2))).
+				Where(C("Id").Eq(12)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=(`age` + ?) * ? WHERE `id` = ?;",
+				Args: []any{1, 2, 12},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.u.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+			// 再 Build 一次，结果是一样的
+			q, err = tc.u.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestUpdater_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	testCases := []struct {
+		name     string
+		u        *Updater[TestModel]
+		wantErr  error
+		affected int64
+	}{
+		{
+			name: "query error",
+			u: func() *Updater[TestModel] {
+				return NewUpdater[TestModel](db).Update(&TestModel{}).
+					Set(C("Invalid"))
+			}(),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "db error",
+			u: func() *Updater[TestModel] {
+				mock.ExpectExec("UPDATE .*").
+					WillReturnError(errors.New("db error"))
+				return NewUpdater[TestModel](db).Update(&TestModel{Age: 18})
+			}(),
+			wantErr: errors.New("db error"),
+		},
+		{
+			name: "exec",
+			u: func() *Updater[TestModel] {
+				res := driver.RowsAffected(1)
+				mock.ExpectExec("UPDATE .*").
+					WillReturnResult(res)
+				return NewUpdater[TestModel](db).Update(&TestModel{Age: 18})
+			}(),
+			affected: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.u.Exec(context.Background())
+			affected, err := res.RowsAffected()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.affected, affected)
+		})
+	}
+}